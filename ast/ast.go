@@ -3,6 +3,7 @@ package ast
 import (
 	"Monkey_1/token"
 	"bytes"
+	"math/big"
 	"strings"
 )
 
@@ -10,6 +11,8 @@ import (
 type Node interface {
 	TokenLiteral() string // 仅用于测试
 	String() string
+	Pos() token.Position // 结点在源码中的起始位置
+	End() token.Position // 结点在源码中的结束位置（最后一个字符之后），[Pos, End)即结点的范围
 }
 
 type Statement interface {
@@ -37,6 +40,20 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
@@ -59,11 +76,20 @@ func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
 
+func (ls *LetStatement) Pos() token.Position { return ls.Token.Pos }
+
+func (ls *LetStatement) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	return ls.Name.End()
+}
+
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
 	out.WriteString(ls.Name.Value) // = ls.Name.string()
-	out.WriteString("=")
+	out.WriteString(" = ")
 	if ls.Value != nil { // ?
 		out.WriteString(ls.Value.String())
 	}
@@ -84,6 +110,10 @@ func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
 
 func (i *Identifier) String() string { return i.Value }
 
+func (i *Identifier) Pos() token.Position { return i.Token.Pos }
+
+func (i *Identifier) End() token.Position { return i.Token.End }
+
 // ReturnStatement --------------------------------------
 // ReturnStatement 是 Statement 接口的实现
 type ReturnStatement struct {
@@ -95,6 +125,15 @@ func (rs *ReturnStatement) statementNode() {}
 
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
 
+func (rs *ReturnStatement) Pos() token.Position { return rs.Token.Pos }
+
+func (rs *ReturnStatement) End() token.Position {
+	if rs.ReturnValue != nil {
+		return rs.ReturnValue.End()
+	}
+	return rs.Token.End
+}
+
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(rs.TokenLiteral() + " ")
@@ -117,6 +156,15 @@ func (es *ExpressionStatement) statementNode() {}
 
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
 
+func (es *ExpressionStatement) Pos() token.Position { return es.Token.Pos }
+
+func (es *ExpressionStatement) End() token.Position {
+	if es.Expression != nil {
+		return es.Expression.End()
+	}
+	return es.Token.End
+}
+
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String() //
@@ -137,6 +185,44 @@ func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 
 func (il *IntegerLiteral) String() string { return il.Token.Literal }
 
+func (il *IntegerLiteral) Pos() token.Position { return il.Token.Pos }
+
+func (il *IntegerLiteral) End() token.Position { return il.Token.End }
+
+// BigIntegerLiteral --------------------------------------
+// BigIntegerLiteral 超出int64范围的整数字面量
+type BigIntegerLiteral struct {
+	Token token.Token
+	Value *big.Int
+}
+
+func (bl *BigIntegerLiteral) expressionNode() {}
+
+func (bl *BigIntegerLiteral) TokenLiteral() string { return bl.Token.Literal }
+
+func (bl *BigIntegerLiteral) String() string { return bl.Token.Literal }
+
+func (bl *BigIntegerLiteral) Pos() token.Position { return bl.Token.Pos }
+
+func (bl *BigIntegerLiteral) End() token.Position { return bl.Token.End }
+
+// FloatLiteral --------------------------------------
+// FloatLiteral 是 Expression 接口的实现，是AST中的一个节点
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+
+func (fl *FloatLiteral) String() string { return fl.Token.Literal }
+
+func (fl *FloatLiteral) Pos() token.Position { return fl.Token.Pos }
+
+func (fl *FloatLiteral) End() token.Position { return fl.Token.End }
+
 // PrefixExpression --------------------------------------
 // PrefixExpression 是 Expression 接口的实现，是AST中的一个节点
 type PrefixExpression struct {
@@ -149,6 +235,15 @@ func (pe *PrefixExpression) expressionNode() {}
 
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
 
+func (pe *PrefixExpression) Pos() token.Position { return pe.Token.Pos }
+
+func (pe *PrefixExpression) End() token.Position {
+	if pe.Right != nil {
+		return pe.Right.End()
+	}
+	return pe.Token.End
+}
+
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -171,6 +266,15 @@ func (ie *InfixExpression) expressionNode() {}
 
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
 
+func (ie *InfixExpression) Pos() token.Position { return ie.Left.Pos() }
+
+func (ie *InfixExpression) End() token.Position {
+	if ie.Right != nil {
+		return ie.Right.End()
+	}
+	return ie.Token.End
+}
+
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -181,6 +285,38 @@ func (ie *InfixExpression) String() string {
 	return out.String()
 }
 
+// AssignExpression --------------------------------------
+// AssignExpression 赋值表达式 x = 1、x += 1、arr[0] = 1，其值为赋值后的值
+type AssignExpression struct {
+	Token    token.Token // 赋值运算符的词法单元 如 = +=
+	Target   Expression  // 被赋值的对象，只能是 *Identifier 或 *IndexExpression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+
+func (ae *AssignExpression) Pos() token.Position { return ae.Target.Pos() }
+
+func (ae *AssignExpression) End() token.Position {
+	if ae.Value != nil {
+		return ae.Value.End()
+	}
+	return ae.Token.End
+}
+
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ae.Target.String())
+	out.WriteString(ae.Operator)
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+	return out.String()
+}
+
 // Boolean --------------------------------------
 type Boolean struct {
 	Token token.Token
@@ -193,6 +329,10 @@ func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 
 func (b *Boolean) String() string { return b.Token.Literal }
 
+func (b *Boolean) Pos() token.Position { return b.Token.Pos }
+
+func (b *Boolean) End() token.Position { return b.Token.End }
+
 // IfExpression --------------------------------------
 type IfExpression struct {
 	Token       token.Token
@@ -205,6 +345,15 @@ func (ie *IfExpression) expressionNode() {}
 
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
 
+func (ie *IfExpression) Pos() token.Position { return ie.Token.Pos }
+
+func (ie *IfExpression) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+	return ie.Consequence.End()
+}
+
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("if")
@@ -218,16 +367,104 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// WhileStatement --------------------------------------
+// WhileStatement while (<条件>) { <循环体> }
+type WhileStatement struct {
+	Token     token.Token // 'while'
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+
+func (ws *WhileStatement) Pos() token.Position { return ws.Token.Pos }
+
+func (ws *WhileStatement) End() token.Position { return ws.Body.End() }
+
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+	return out.String()
+}
+
+// ForStatement --------------------------------------
+// ForStatement for (<变量> in <可迭代对象>) { <循环体> }
+type ForStatement struct {
+	Token    token.Token // 'for'
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode() {}
+
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+
+func (fs *ForStatement) Pos() token.Position { return fs.Token.Pos }
+
+func (fs *ForStatement) End() token.Position { return fs.Body.End() }
+
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("for(")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+	return out.String()
+}
+
+// BreakStatement --------------------------------------
+type BreakStatement struct {
+	Token token.Token // 'break'
+}
+
+func (bs *BreakStatement) statementNode() {}
+
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+
+func (bs *BreakStatement) Pos() token.Position { return bs.Token.Pos }
+
+func (bs *BreakStatement) End() token.Position { return bs.Token.End }
+
+func (bs *BreakStatement) String() string { return bs.TokenLiteral() + ";" }
+
+// ContinueStatement --------------------------------------
+type ContinueStatement struct {
+	Token token.Token // 'continue'
+}
+
+func (cs *ContinueStatement) statementNode() {}
+
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+
+func (cs *ContinueStatement) Pos() token.Position { return cs.Token.Pos }
+
+func (cs *ContinueStatement) End() token.Position { return cs.Token.End }
+
+func (cs *ContinueStatement) String() string { return cs.TokenLiteral() + ";" }
+
 // BlockStatement --------------------------------------
 type BlockStatement struct {
-	Token      token.Token
+	Token      token.Token // '{'
 	Statements []Statement
+	RBrace     token.Token // '}'，仅用于记录结束位置
 }
 
 func (bs *BlockStatement) statementNode() {}
 
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
 
+func (bs *BlockStatement) Pos() token.Position { return bs.Token.Pos }
+
+func (bs *BlockStatement) End() token.Position { return bs.RBrace.End }
+
 func (bs *BlockStatement) String() string {
 	out := bytes.Buffer{}
 	for _, s := range bs.Statements {
@@ -239,8 +476,11 @@ func (bs *BlockStatement) String() string {
 // FunctionLiteral --------------------------------------
 // 定义函数， 函数可以作为
 type FunctionLiteral struct {
-	Token      token.Token     // Token.TokenType = FUNCTION, Token.Literal = 函数名
+	Token      token.Token     // Token.TokenType = FUNCTION, Token.Literal = "fn"
+	Name       string          // let语句绑定的函数名，匿名函数为空，仅用于调用栈
 	Parameters []*Identifier   // 参数列表，是标识符
+	Defaults   []Expression    // 与Parameters一一对应的默认值，没有默认值的参数为nil
+	Rest       *Identifier     // 剩余参数 ...rest，没有则为nil
 	Body       *BlockStatement // 块语句
 }
 
@@ -248,32 +488,53 @@ func (fl *FunctionLiteral) expressionNode() {}
 
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
 
+func (fl *FunctionLiteral) Pos() token.Position { return fl.Token.Pos }
+
+func (fl *FunctionLiteral) End() token.Position { return fl.Body.End() }
+
 func (fl *FunctionLiteral) String() string {
 	out := bytes.Buffer{}
-	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
-	}
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(FormatParameters(fl.Parameters, fl.Defaults, fl.Rest))
 	out.WriteString(")")
 	out.WriteString(fl.Body.String())
 	return out.String()
 }
 
+// FormatParameters 返回形如 `x, y = 10, ...rest` 的参数列表，defaults可以为nil
+func FormatParameters(params []*Identifier, defaults []Expression, rest *Identifier) string {
+	var list []string
+	for i, p := range params {
+		if i < len(defaults) && defaults[i] != nil {
+			list = append(list, p.String()+" = "+defaults[i].String())
+		} else {
+			list = append(list, p.String())
+		}
+	}
+	if rest != nil {
+		list = append(list, "..."+rest.String())
+	}
+	return strings.Join(list, ", ")
+}
+
 // CallExpression --------------------------------------
 // 调用函数
 type CallExpression struct {
 	Token     token.Token  // '('
 	Function  Expression   // 函数的Identifier 节点
 	Arguments []Expression // 参数列表
+	RParen    token.Token  // ')'，仅用于记录结束位置
 }
 
 func (ce *CallExpression) expressionNode() {}
 
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
 
+func (ce *CallExpression) Pos() token.Position { return ce.Function.Pos() }
+
+func (ce *CallExpression) End() token.Position { return ce.RParen.End }
+
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 	//args := []string{}
@@ -289,6 +550,39 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// InterpolatedString --------------------------------------
+// InterpolatedString 插值字符串 "hello ${name}"，Parts 依次是字符串片段和 ${} 中的表达式
+// 字符串片段是Token类型为TEMPLATE_*的StringLiteral，以便与插值中的字符串字面量区分
+type InterpolatedString struct {
+	Token token.Token // TEMPLATE_HEAD
+	Parts []Expression
+	Tail  token.Token // TEMPLATE_TAIL，仅用于记录结束位置
+}
+
+func (is *InterpolatedString) expressionNode() {}
+
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+
+func (is *InterpolatedString) Pos() token.Position { return is.Token.Pos }
+
+func (is *InterpolatedString) End() token.Position { return is.Tail.End }
+
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+	out.WriteString("\"")
+	for _, part := range is.Parts {
+		if sl, ok := part.(*StringLiteral); ok && sl.Token.Type != token.STRING {
+			out.WriteString(sl.Value)
+			continue
+		}
+		out.WriteString("${")
+		out.WriteString(part.String())
+		out.WriteString("}")
+	}
+	out.WriteString("\"")
+	return out.String()
+}
+
 // StringLiteral --------------------------------------
 type StringLiteral struct {
 	Token token.Token
@@ -301,16 +595,25 @@ func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 
 func (sl *StringLiteral) String() string { return sl.Value }
 
+func (sl *StringLiteral) Pos() token.Position { return sl.Token.Pos }
+
+func (sl *StringLiteral) End() token.Position { return sl.Token.End }
+
 // ArrayLiteral -------
 type ArrayLiteral struct {
-	Token    token.Token
+	Token    token.Token // '['
 	Elements []Expression
+	RBracket token.Token // ']'，仅用于记录结束位置
 }
 
 func (al *ArrayLiteral) expressionNode() {}
 
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
 
+func (al *ArrayLiteral) Pos() token.Position { return al.Token.Pos }
+
+func (al *ArrayLiteral) End() token.Position { return al.RBracket.End }
+
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 	out.WriteString("[")
@@ -325,15 +628,20 @@ func (al *ArrayLiteral) String() string {
 
 // IndexExpression -------
 type IndexExpression struct {
-	Token           token.Token
+	Token           token.Token // '['
 	ArrayIdentifier Expression
 	Index           Expression
+	RBracket        token.Token // ']'，仅用于记录结束位置
 }
 
 func (ie *IndexExpression) expressionNode() {}
 
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
 
+func (ie *IndexExpression) Pos() token.Position { return ie.ArrayIdentifier.Pos() }
+
+func (ie *IndexExpression) End() token.Position { return ie.RBracket.End }
+
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(") // ?
@@ -345,21 +653,70 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression 切片 a[start:stop:step]，三个部分都可以省略，省略的部分为nil
+type SliceExpression struct {
+	Token           token.Token // '['
+	ArrayIdentifier Expression
+	Start           Expression
+	Stop            Expression
+	Step            Expression
+	RBracket        token.Token // ']'，仅用于记录结束位置
+}
+
+func (se *SliceExpression) expressionNode() {}
+
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+
+func (se *SliceExpression) Pos() token.Position { return se.ArrayIdentifier.Pos() }
+
+func (se *SliceExpression) End() token.Position { return se.RBracket.End }
+
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(se.ArrayIdentifier.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.Stop != nil {
+		out.WriteString(se.Stop.String())
+	}
+	if se.Step != nil {
+		out.WriteString(":")
+		out.WriteString(se.Step.String())
+	}
+	out.WriteString("])")
+	return out.String()
+}
+
 // HashLiteral
 type HashLiteral struct {
-	Token token.Token
-	Pairs map[Expression]Expression
+	Token  token.Token // '{'
+	Pairs  []HashPair  // 按源码中的顺序排列，求值和打印都遵循这个顺序
+	RBrace token.Token // '}'，仅用于记录结束位置
+}
+
+// HashPair 哈希表字面量中的一个键值对
+type HashPair struct {
+	Key   Expression
+	Value Expression
 }
 
 func (hl *HashLiteral) expressionNode() {}
 
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
 
+func (hl *HashLiteral) Pos() token.Position { return hl.Token.Pos }
+
+func (hl *HashLiteral) End() token.Position { return hl.RBrace.End }
+
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 	var pairs []string
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ","))
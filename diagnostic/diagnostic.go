@@ -0,0 +1,65 @@
+package diagnostic
+
+import (
+	"Monkey_1/token"
+	"fmt"
+)
+
+// Severity 诊断信息的严重程度
+type Severity int
+
+const (
+	Error   Severity = iota // 错误，源码无法被执行
+	Warning                 // 警告，源码可以执行但可能有问题
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// Code 诊断编号，L开头的来自词法分析，P开头的来自语法分析，方便工具按类别处理而不必解析Message
+type Code string
+
+const (
+	IllegalCharacter    Code = "L001" // 无法识别的字符
+	UnterminatedComment Code = "L002" // 块注释没有闭合
+	UnterminatedString  Code = "L003" // 字符串没有闭合
+	InvalidEscape       Code = "L004" // 字符串中的转义序列不合法
+	InvalidNumber       Code = "L005" // 数字字面量的格式不合法，如 12abc、0x
+	ReadError           Code = "L006" // 从io.Reader读取源码时出错
+
+	UnexpectedToken Code = "P001" // 下一个token不是期待的类型
+	NoPrefixParseFn Code = "P002" // token不能作为表达式的开头
+	InvalidInteger  Code = "P003" // 整数字面量无法解析
+	InvalidFloat    Code = "P004" // 浮点数字面量无法解析
+	InvalidParam    Code = "P005" // 函数参数列表不合法
+	InvalidAssign   Code = "P006" // 赋值运算符左侧不是变量或索引表达式
+	InvalidBreak    Code = "P007" // break或continue不在循环中
+)
+
+// Diagnostic 一条诊断信息，取代原先Parser.Errors()返回的字符串
+type Diagnostic struct {
+	Severity   Severity
+	Code       Code
+	Message    string
+	Pos        token.Position    // 出错范围的起始位置
+	End        token.Position    // 出错范围的结束位置
+	Expected   []token.TokenType // 期待的token类型，可以为空
+	Actual     token.TokenType   // 实际遇到的token类型，可以为空
+	Suggestion string            // 修复建议，可以为空
+}
+
+// String 返回形如 `1:5: error[P001]: expected next token to be ), got ; instead` 的文本
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s[%s]: %s", d.Pos, d.Severity, d.Code, d.Message)
+}
+
+// Error 使Diagnostic实现error接口
+func (d Diagnostic) Error() string { return d.String() }
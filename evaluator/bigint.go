@@ -0,0 +1,179 @@
+package evaluator
+
+import (
+	"Monkey_1/object"
+	"math"
+	"math/big"
+)
+
+// checkIntegerOperand 检查整数运算的右操作数，除数不能为0，移位的位数不能为负数
+func checkIntegerOperand(operator string, right int64) *object.Error {
+	switch {
+	case operator == "/" && right == 0:
+		return newError("division by zero")
+	case operator == "%" && right == 0:
+		return newError("modulo by zero")
+	case (operator == "<<" || operator == ">>") && right < 0:
+		return newError("negative shift count: %d", right)
+	}
+	return nil
+}
+
+// int64Arithmetic 对int64做 + - * / % ** << 运算，ok为false表示结果溢出，需要改用BigInt计算
+func int64Arithmetic(operator string, a, b int64) (result int64, ok bool) {
+	switch operator {
+	case "+":
+		result = a + b
+		// 同号相加，结果却与之异号，说明溢出
+		return result, !(a > 0 && b > 0 && result < 0 || a < 0 && b < 0 && result >= 0)
+	case "-":
+		result = a - b
+		return result, !(a >= 0 && b < 0 && result < 0 || a < 0 && b > 0 && result >= 0)
+	case "*":
+		if a == 0 || b == 0 {
+			return 0, true
+		}
+		result = a * b
+		if a == -1 && b == math.MinInt64 || b == -1 && a == math.MinInt64 || result/b != a {
+			return result, false
+		}
+		return result, true
+	case "/":
+		// 唯一的溢出情况：math.MinInt64 / -1
+		if a == math.MinInt64 && b == -1 {
+			return 0, false
+		}
+		return a / b, true
+	case "%":
+		// math.MinInt64 % -1 在Go中定义为0，不会溢出
+		return a % b, true
+	case "<<":
+		if b >= 63 {
+			return 0, a == 0
+		}
+		result = a << b
+		// 移回去不能还原，说明有效位被移出或符号位改变
+		return result, result>>b == a
+	case "**":
+		return int64Pow(a, b)
+	}
+	return 0, false
+}
+
+// int64Pow 快速幂，exp不能为负数，ok为false表示结果溢出
+func int64Pow(base, exp int64) (result int64, ok bool) {
+	result = 1
+	for exp > 0 {
+		if exp&1 == 1 {
+			if result, ok = int64Arithmetic("*", result, base); !ok {
+				return 0, false
+			}
+		}
+		exp >>= 1
+		if exp > 0 {
+			if base, ok = int64Arithmetic("*", base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+	return result, true
+}
+
+// evalBigIntInfixExpression 任意精度整数的中缀表达式求值，结果落在int64范围内时重新用Integer表示
+func evalBigIntInfixExpression(operator string, leftObj, rightObj object.Object) object.Object {
+	left := toBigInt(leftObj)
+	right := toBigInt(rightObj)
+	switch operator {
+	case "+":
+		return newInteger(new(big.Int).Add(left, right))
+	case "-":
+		return newInteger(new(big.Int).Sub(left, right))
+	case "*":
+		if bits := left.BitLen() + right.BitLen(); bits > maxIntegerBits {
+			return newError("integer result too large: %d bits", bits)
+		}
+		return newInteger(new(big.Int).Mul(left, right))
+	case "/":
+		if right.Sign() == 0 {
+			return newError("division by zero")
+		}
+		// Quo 向零取整，与int64的 / 保持一致
+		return newInteger(new(big.Int).Quo(left, right))
+	case "%":
+		if right.Sign() == 0 {
+			return newError("modulo by zero")
+		}
+		// Rem 的结果与被除数同号，与int64的 % 保持一致
+		return newInteger(new(big.Int).Rem(left, right))
+	case "&":
+		return newInteger(new(big.Int).And(left, right))
+	case "|":
+		return newInteger(new(big.Int).Or(left, right))
+	case "^":
+		return newInteger(new(big.Int).Xor(left, right))
+	case "<<":
+		if right.Sign() < 0 {
+			return newError("negative shift count: %s", right)
+		}
+		if !right.IsInt64() || right.Int64() > maxIntegerBits-int64(left.BitLen()) {
+			return newError("shift count too large: %s", right)
+		}
+		return newInteger(new(big.Int).Lsh(left, uint(right.Int64())))
+	case ">>":
+		if right.Sign() < 0 {
+			return newError("negative shift count: %s", right)
+		}
+		// 右移不会使结果变大，移出所有位后结果是0或-1，与int64的 >> 一样截断位数
+		// Rsh 对负数是算术右移，与int64的 >> 保持一致
+		n := uint(left.BitLen())
+		if right.IsInt64() && right.Int64() < int64(n) {
+			n = uint(right.Int64())
+		}
+		return newInteger(new(big.Int).Rsh(left, n))
+	case "**":
+		if right.Sign() < 0 {
+			return &object.Float{Value: math.Pow(toFloat(leftObj), toFloat(rightObj))}
+		}
+		// 结果的位数不超过 left.BitLen() * right，0、1、-1的幂不会变大
+		if !right.IsInt64() || left.CmpAbs(big.NewInt(1)) > 0 && right.Int64() > maxIntegerBits/int64(left.BitLen()) {
+			return newError("exponent too large: %s", right)
+		}
+		return newInteger(new(big.Int).Exp(left, right, nil))
+	case "==":
+		return nativeBoolToBooleanObject(left.Cmp(right) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(left.Cmp(right) != 0)
+	case ">":
+		return nativeBoolToBooleanObject(left.Cmp(right) > 0)
+	case "<":
+		return nativeBoolToBooleanObject(left.Cmp(right) < 0)
+	case ">=":
+		return nativeBoolToBooleanObject(left.Cmp(right) >= 0)
+	case "<=":
+		return nativeBoolToBooleanObject(left.Cmp(right) <= 0)
+	default:
+		return newError("unknown operator: %s %s %s", leftObj.Type(), operator, rightObj.Type())
+	}
+}
+
+// maxIntegerBits 乘法、乘方和左移的结果的最大位数，在计算之前按操作数估算，避免一个表达式就耗尽内存
+const maxIntegerBits = 1 << 24
+
+// newInteger 根据大小选择Integer或BigInt
+func newInteger(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+	return &object.BigInt{Value: value}
+}
+
+// toBigInt 将整数转换为*big.Int，调用前需要保证isInteger(obj)
+func toBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInt:
+		return obj.Value
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package evaluator
+
+import (
+	"Monkey_1/object"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// 超出执行预算时错误的Cause，可以用 errors.Is 与其他运行时错误区分
+var (
+	ErrStepLimitExceeded  = errors.New("step limit exceeded")
+	ErrAllocLimitExceeded = errors.New("allocation limit exceeded")
+)
+
+// Usage 一次求值消耗的资源，对同一个程序总是相同，与机器的速度无关
+type Usage struct {
+	Steps       int64 // 求值的结点数量
+	Allocations int64 // 分配的对象数量
+	AllocBytes  int64 // 分配的字节数，是估算值
+}
+
+// Usage 返回最近一次（或正在进行的）求值消耗的资源
+func (in *Interpreter) Usage() Usage { return in.usage }
+
+// step 记录求值了一个结点，超出预算时返回错误
+func (in *Interpreter) step() *object.Error {
+	in.usage.Steps++
+	if in.MaxSteps > 0 && in.usage.Steps > in.MaxSteps {
+		return &object.Error{Message: fmt.Sprintf("step limit %d exceeded", in.MaxSteps),
+			Cause: ErrStepLimitExceeded}
+	}
+	return nil
+}
+
+// allocate 记录新分配的对象obj，超出预算时返回错误，否则返回obj本身
+// 错误以及TRUE、FALSE、NULL这些共享的实例不是新分配的，不计入
+func (in *Interpreter) allocate(obj object.Object) object.Object {
+	switch obj {
+	case nil, TRUE, FALSE, NULL:
+		return obj
+	}
+	if isError(obj) {
+		return obj
+	}
+	if err := in.charge(1, sizeOf(obj)); err != nil {
+		return err
+	}
+	return obj
+}
+
+// Allocate 实现 object.Allocator，供内置函数记录新分配的对象，见 allocate
+func (in *Interpreter) Allocate(obj object.Object) object.Object { return in.allocate(obj) }
+
+// Charge 实现 object.Allocator，供内置函数在分配之前记录将要分配的对象，见 charge
+func (in *Interpreter) Charge(count, size int64) *object.Error { return in.charge(count, size) }
+
+// charge 记录分配了count个对象，共size字节，超出预算时返回错误
+func (in *Interpreter) charge(count, size int64) *object.Error {
+	in.usage.Allocations += count
+	if size > math.MaxInt64-in.usage.AllocBytes {
+		in.usage.AllocBytes = math.MaxInt64 // 避免溢出
+	} else {
+		in.usage.AllocBytes += size
+	}
+	switch {
+	case in.MaxAllocations > 0 && in.usage.Allocations > in.MaxAllocations:
+		return &object.Error{Message: fmt.Sprintf("allocation limit %d objects exceeded", in.MaxAllocations),
+			Cause: ErrAllocLimitExceeded}
+	case in.MaxAllocBytes > 0 && in.usage.AllocBytes > in.MaxAllocBytes:
+		return &object.Error{Message: fmt.Sprintf("allocation limit %d bytes exceeded", in.MaxAllocBytes),
+			Cause: ErrAllocLimitExceeded}
+	}
+	return nil
+}
+
+// evalInfix 求中缀表达式的值并记录分配。结果可能很大的运算（字符串的拼接与重复、数组的拼接、
+// 大整数的乘法、乘方和左移）在分配之前按结果的大小记录，超出预算时不会真的分配
+func (in *Interpreter) evalInfix(operator string, left, right object.Object) object.Object {
+	size := infixResultSize(operator, left, right)
+	if size == 0 {
+		return in.allocate(evalInfixExpression(operator, left, right))
+	}
+	if err := in.charge(1, size); err != nil {
+		return err
+	}
+	return evalInfixExpression(operator, left, right)
+}
+
+// infixResultSize 估算字符串的拼接与重复、数组的拼接、大整数运算的结果占用的字节数，其他运算返回0
+// 结果过大时返回math.MaxInt64，由预算或运算本身报告错误
+func infixResultSize(operator string, left, right object.Object) int64 {
+	switch {
+	case operator == "+" && left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return objectSize + int64(len(left.(*object.String).Value)) + int64(len(right.(*object.String).Value))
+	case operator == "+" && left.Type() == object.ARRAY_OBJ && right.Type() == object.ARRAY_OBJ:
+		return objectSize + int64(len(left.(*object.Array).Elements)+len(right.(*object.Array).Elements))*slotSize
+	case operator == "*" && left.Type() == object.STRING_OBJ && right.Type() == object.INTEGER_OBJ:
+		return repeatSize(left.(*object.String).Value, right.(*object.Integer).Value)
+	case operator == "*" && left.Type() == object.INTEGER_OBJ && right.Type() == object.STRING_OBJ:
+		return repeatSize(right.(*object.String).Value, left.(*object.Integer).Value)
+	case isInteger(left) && isInteger(right):
+		return bigIntResultSize(operator, toBigInt(left), toBigInt(right))
+	}
+	return 0
+}
+
+// bigIntResultSize 按操作数的位数估算 * ** << 的结果占用的字节数，结果能用int64表示时返回0
+func bigIntResultSize(operator string, left, right *big.Int) int64 {
+	var bits int64
+	switch operator {
+	case "*":
+		bits = int64(left.BitLen() + right.BitLen())
+	case "**":
+		// 0、1、-1的幂不会变大，负指数的结果是浮点数
+		if left.CmpAbs(big.NewInt(1)) <= 0 || right.Sign() < 0 {
+			return 0
+		}
+		if !right.IsInt64() || right.Int64() > math.MaxInt64/int64(left.BitLen()) {
+			return math.MaxInt64
+		}
+		bits = int64(left.BitLen()) * right.Int64()
+	case "<<":
+		if left.Sign() == 0 || right.Sign() < 0 {
+			return 0
+		}
+		if !right.IsInt64() || right.Int64() > math.MaxInt64-int64(left.BitLen()) {
+			return math.MaxInt64
+		}
+		bits = int64(left.BitLen()) + right.Int64()
+	}
+	if bits <= 64 {
+		return 0
+	}
+	return objectSize + bits/8
+}
+
+// repeatSize 估算字符串value重复n次的结果占用的字节数，n不大于0时返回0
+func repeatSize(value string, n int64) int64 {
+	switch {
+	case n <= 0:
+		return 0
+	case len(value) > 0 && n > (math.MaxInt64-objectSize)/int64(len(value)):
+		return math.MaxInt64
+	}
+	return objectSize + int64(len(value))*n
+}
+
+// 估算对象大小用到的常量，只需要大致反映内存的占用
+const (
+	objectSize = 16 // 对象本身
+	slotSize   = 16 // 数组的一个元素
+	pairSize   = 48 // 哈希表的一个键值对及其索引
+)
+
+// sizeOf 估算对象占用的字节数，不包括它引用的其他对象，那些对象在分配时已经计入
+func sizeOf(obj object.Object) int64 {
+	switch obj := obj.(type) {
+	case *object.String:
+		return objectSize + int64(len(obj.Value))
+	case *object.BigInt:
+		return objectSize + int64(len(obj.Value.Bits()))*8
+	case *object.Array:
+		return objectSize + int64(len(obj.Elements))*slotSize
+	case *object.Hash:
+		return objectSize + int64(len(obj.Pairs))*pairSize
+	default:
+		return objectSize
+	}
+}
@@ -3,20 +3,24 @@ package evaluator
 import (
 	"Monkey_1/object"
 	"fmt"
+	"unicode/utf8"
 )
 
 var builtins = map[string]*object.Builtin{
 	"len": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(alloc object.Allocator, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
 			}
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				// 字符的数量而不是字节数，字节数可以用 len(bytes(s)) 得到
+				return alloc.Allocate(&object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))})
 			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
+				return alloc.Allocate(&object.Integer{Value: int64(len(arg.Elements))})
+			case *object.Range:
+				return alloc.Allocate(&object.Integer{Value: int64(arg.Len())})
 			default:
 				return newError("argument to `len` not supported, got %s",
 					args[0].Type())
@@ -24,7 +28,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"first": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(alloc object.Allocator, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
@@ -42,7 +46,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"last": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(alloc object.Allocator, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
@@ -61,7 +65,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"rest": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(alloc object.Allocator, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
@@ -72,7 +76,7 @@ var builtins = map[string]*object.Builtin{
 				if length > 0 {
 					newElements := make([]object.Object, length-1, length-1)
 					copy(newElements, arg.Elements[1:length])
-					return &object.Array{Elements: newElements}
+					return alloc.Allocate(&object.Array{Elements: newElements})
 				}
 				return NULL
 			default:
@@ -82,7 +86,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"push": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(alloc object.Allocator, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d, want=2",
 					len(args))
@@ -94,15 +98,94 @@ var builtins = map[string]*object.Builtin{
 				newElements := make([]object.Object, length+1, length+1)
 				copy(newElements, arg.Elements[0:length])
 				newElements[length] = args[1]
-				return &object.Array{Elements: newElements}
+				return alloc.Allocate(&object.Array{Elements: newElements})
 			default:
 				return newError("argument to `first` must be array, got %s",
 					args[0].Type())
 			}
 		},
 	},
+	// tuple(a, b, ...) 用参数创建一个不可变数组（元组），可以作为哈希表的键
+	"tuple": &object.Builtin{
+		Fn: func(alloc object.Allocator, args ...object.Object) object.Object {
+			elements := make([]object.Object, len(args))
+			copy(elements, args)
+			return alloc.Allocate(&object.Array{Elements: elements, Frozen: true})
+		},
+	},
+	// freeze(array) 返回数组的不可变副本，只冻结这一层，元素本身不受影响
+	"freeze": &object.Builtin{
+		Fn: func(alloc object.Allocator, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			array, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `freeze` must be ARRAY, got %s",
+					args[0].Type())
+			}
+			elements := make([]object.Object, len(array.Elements))
+			copy(elements, array.Elements)
+			return alloc.Allocate(&object.Array{Elements: elements, Frozen: true})
+		},
+	},
+	// range(end)、range(start, end) 或 range(start, end, step)，产生 [start, end) 中的整数
+	"range": &object.Builtin{
+		Fn: func(alloc object.Allocator, args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=1..3",
+					len(args))
+			}
+			bounds := make([]int64, len(args))
+			for i, arg := range args {
+				integer, ok := arg.(*object.Integer)
+				if !ok {
+					return newError("argument to `range` must be INTEGER, got %s",
+						arg.Type())
+				}
+				bounds[i] = integer.Value
+			}
+			r := &object.Range{Step: 1}
+			switch len(bounds) {
+			case 1:
+				r.End = bounds[0]
+			case 2:
+				r.Start, r.End = bounds[0], bounds[1]
+			case 3:
+				r.Start, r.End, r.Step = bounds[0], bounds[1], bounds[2]
+			}
+			if r.Step == 0 {
+				return newError("range step must not be zero")
+			}
+			return alloc.Allocate(r)
+		},
+	},
+	// bytes(s) 返回字符串UTF-8编码的各个字节，用于需要按字节处理字符串的场合
+	"bytes": &object.Builtin{
+		Fn: func(alloc object.Allocator, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `bytes` must be STRING, got %s",
+					args[0].Type())
+			}
+			// 先记录每个字节对应的Integer，超出预算时不必再分配
+			if err := alloc.Charge(int64(len(str.Value)), int64(len(str.Value))*objectSize); err != nil {
+				return err
+			}
+			elements := make([]object.Object, len(str.Value))
+			for i := 0; i < len(str.Value); i++ {
+				elements[i] = &object.Integer{Value: int64(str.Value[i])}
+			}
+			return alloc.Allocate(&object.Array{Elements: elements})
+		},
+	},
 	"puts": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(alloc object.Allocator, args ...object.Object) object.Object {
 			for _, arg := range args {
 				fmt.Println(arg.Inspect())
 			}
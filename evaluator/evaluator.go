@@ -3,7 +3,12 @@ package evaluator
 import (
 	"Monkey_1/ast"
 	"Monkey_1/object"
+	"context"
 	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"unicode/utf8"
 )
 
 // 实例，此后的这些值都是指向这些实例的，无需额外新建实例。
@@ -11,25 +16,127 @@ var (
 	TRUE  = &object.Boolean{Value: true}
 	FALSE = &object.Boolean{Value: false}
 	NULL  = &object.Null{}
+
+	// 循环控制信号，break和continue不携带值，因此也只需要一个实例
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
-// Eval 输入ast.Node，内部求值，返回一个值的表达 object.Object
+// DefaultMaxCallDepth 默认的函数调用最大嵌套深度
+const DefaultMaxCallDepth = 10000
+
+// Interpreter 解释器实例，保存求值时的配置和状态，不能被多个goroutine同时使用
+type Interpreter struct {
+	// MaxCallDepth 函数调用的最大嵌套深度，超过时求值以错误结束，
+	// 避免无穷递归耗尽宿主语言的调用栈（这种崩溃无法recover）。不大于0时不限制
+	MaxCallDepth int
+
+	// 执行预算，超出时求值以错误结束，不大于0时不限制。每次求值重新计算，见 Usage
+	MaxSteps       int64 // 最多求值的结点数量
+	MaxAllocations int64 // 最多分配的对象数量
+	MaxAllocBytes  int64 // 最多分配的字节数（估算值）
+
+	usage Usage
+	depth int             // 当前的函数调用嵌套深度，尾调用不增加深度
+	ctx   context.Context // 本次求值的ctx，见 EvalContext
+	done  <-chan struct{} // ctx.Done()，不会被取消的ctx为nil
+}
+
+// New 创建一个使用默认配置的解释器
+func New() *Interpreter {
+	return &Interpreter{MaxCallDepth: DefaultMaxCallDepth}
+}
+
+// Eval 使用默认配置的解释器求值，见 Interpreter.Eval
 func Eval(node ast.Node, env *object.Environment) object.Object {
-	// 出现了Eval()的地方都需要判断是否出错
+	return New().Eval(node, env)
+}
+
+// EvalContext 使用默认配置的解释器求值，见 Interpreter.EvalContext
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	return New().EvalContext(ctx, node, env)
+}
+
+// Eval 输入ast.Node，内部求值，返回一个值的表达 object.Object
+// 求值过程中宿主语言的panic（如内置函数中的bug）会被转换为 object.Error，不会导致宿主进程崩溃
+func (in *Interpreter) Eval(node ast.Node, env *object.Environment) object.Object {
+	return in.EvalContext(context.Background(), node, env)
+}
+
+// EvalContext 与Eval相同，但ctx被取消或超时后，求值会在下一次函数调用、循环迭代或块语句处以错误结束，
+// 该错误的Cause是ctx.Err()，可以用 errors.Is(err, context.DeadlineExceeded) 等与其他运行时错误区分
+// 在求值过程中（如内置函数中）再次调用时，嵌套的求值沿用外层的调用深度和执行预算，返回后恢复外层的状态
+func (in *Interpreter) EvalContext(ctx context.Context, node ast.Node, env *object.Environment) (result object.Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = newError("internal error: %v", r)
+		}
+	}()
+	if in.ctx == nil {
+		in.depth = 0
+		in.usage = Usage{}
+	}
+	depth, prevCtx, prevDone := in.depth, in.ctx, in.done
+	in.ctx, in.done = ctx, ctx.Done()
+	defer func() { in.depth, in.ctx, in.done = depth, prevCtx, prevDone }()
+	return in.resolveTailCall(in.eval(node, env))
+}
+
+// checkCancelled ctx已被取消时返回表示取消的错误，否则返回nil
+func (in *Interpreter) checkCancelled() *object.Error {
+	select {
+	case <-in.done:
+		err := in.ctx.Err()
+		return &object.Error{Message: "evaluation cancelled: " + err.Error(), Cause: err}
+	default:
+		return nil
+	}
+}
+
+// resolveTailCall 执行不在函数中的return语句产生的尾调用，如程序顶层的 return f(x)
+func (in *Interpreter) resolveTailCall(obj object.Object) object.Object {
+	switch obj := obj.(type) {
+	case *object.TailCall:
+		return in.applyFunction(obj.Function, obj.Arguments, obj.Call)
+	case *object.ReturnValue:
+		if tc, ok := obj.Value.(*object.TailCall); ok {
+			return &object.ReturnValue{Value: in.applyFunction(tc.Function, tc.Arguments, tc.Call)}
+		}
+	}
+	return obj
+}
+
+// eval 实际的求值过程，递归求值时调用 eval 而不是 Eval，避免每个结点都设置一次recover
+func (in *Interpreter) eval(node ast.Node, env *object.Environment) object.Object {
+	if err := in.step(); err != nil {
+		return withPos(err, node)
+	}
+	return in.evalNode(node, env)
+}
+
+// evalNode 根据结点的类型求值，新分配的对象需要用 allocate 记录
+func (in *Interpreter) evalNode(node ast.Node, env *object.Environment) object.Object {
+	// 出现了eval()的地方都需要判断是否出错
 	// node的类型断言
 	switch node := node.(type) {
 	// AST的根节点
 	case *ast.Program:
 		// evalStatements 会逐行执行代码，并没有考虑嵌套，导致嵌套遇到return时，会立即返回第一个return
 		//return evalStatements(node.Statements)
-		return evalProgram(node, env)
+		return in.evalProgram(node, env)
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return in.eval(node.Expression, env)
 
 	// 表达式
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		return withPos(in.allocate(&object.Integer{Value: node.Value}), node)
+
+	case *ast.BigIntegerLiteral:
+		return withPos(in.allocate(&object.BigInt{Value: node.Value}), node)
+
+	case *ast.FloatLiteral:
+		return withPos(in.allocate(&object.Float{Value: node.Value}), node)
 
 	case *ast.Boolean:
 		// 以下语句每次都创建object.Boolean，实际上只需要true和false的引用即可
@@ -40,86 +147,117 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return FALSE
 
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := in.eval(node.Right, env)
+		if isAbrupt(right) {
 			return right // 阻断返回值，否则返回的是，返回值为错误的obj
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return withPos(in.allocate(evalPrefixExpression(node.Operator, right)), node)
 
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		if node.Operator == "&&" || node.Operator == "||" {
+			// 逻辑运算符需要短路求值，不能先对右侧求值
+			return in.evalLogicalExpression(node, env)
+		}
+		left := in.eval(node.Left, env)
+		if isAbrupt(left) {
 			return left // 阻断返回值，否则返回的是，返回值为错误的obj
 		}
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := in.eval(node.Right, env)
+		if isAbrupt(right) {
 			return right // 阻断返回值，否则返回的是，返回值为错误的obj
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return withPos(in.evalInfix(node.Operator, left, right), node)
 
 	case *ast.BlockStatement: // ？
 		//return evalStatements(node.Statements)
-		return evalBlockStatement(node, env)
+		return in.evalBlockStatement(node, env, false)
 
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return in.evalIfExpression(node, env)
 
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		// return的值总是处于尾部位置
+		val := in.evalTail(node.ReturnValue, env)
+		if isAbrupt(val) {
 			return val // 阻断返回值，否则返回的是，返回值为错误的obj
 		}
 		return &object.ReturnValue{Value: val} // return 终止了Eval的执行
 
+	case *ast.WhileStatement:
+		return in.evalWhileStatement(node, env)
+
+	case *ast.ForStatement:
+		return in.evalForStatement(node, env)
+
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
+
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
+		val := in.eval(node.Value, env)
+		if isAbrupt(val) {
 			return val
 		}
 		env.Set(node.Name.Value, val)
 
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return withPos(evalIdentifier(node, env), node)
+
+	case *ast.AssignExpression:
+		return withPos(in.evalAssignExpression(node, env), node)
 
 	case *ast.FunctionLiteral:
 		// 简单地将参数列表和函数体赋值
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return withPos(in.allocate(&object.Function{Name: node.Name, Parameters: params, Defaults: node.Defaults,
+			Rest: node.Rest, Env: env, Body: body}), node)
 
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
+		function := in.eval(node.Function, env)
+		if isAbrupt(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
+		args := in.evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isAbrupt(args[0]) {
 			// 只有一个参数 且 该参数是error
 			return args[0]
 		}
-		return applyFunction(function, args)
+		return in.applyFunction(function, args, node)
 	case *ast.StringLiteral:
-		return &object.String{Value: node.Value}
+		return withPos(in.allocate(&object.String{Value: node.Value}), node)
+	case *ast.InterpolatedString:
+		return withPos(in.allocate(in.evalInterpolatedString(node, env)), node)
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
+		elements := in.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isAbrupt(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return withPos(in.allocate(&object.Array{Elements: elements}), node)
 
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return withPos(in.allocate(in.evalHashLiteral(node, env)), node)
 
 	case *ast.IndexExpression:
-		arrayIdentifier := Eval(node.ArrayIdentifier, env)
-		if isError(arrayIdentifier) {
+		arrayIdentifier := in.eval(node.ArrayIdentifier, env)
+		if isAbrupt(arrayIdentifier) {
 			return arrayIdentifier
 		}
-		index := Eval(node.Index, env)
-		if isError(index) {
+		index := in.eval(node.Index, env)
+		if isAbrupt(index) {
 			return index
 		}
-		return evalIndexExpression(arrayIdentifier, index)
+		result := evalIndexExpression(arrayIdentifier, index)
+		if arrayIdentifier.Type() == object.STRING_OBJ {
+			// 字符串的索引结果是新的字符串，数组和哈希表的索引结果是已有的元素
+			result = in.allocate(result)
+		}
+		return withPos(result, node)
+
+	case *ast.SliceExpression:
+		return withPos(in.evalSliceExpression(node, env), node)
 	}
 
 	return nil
@@ -169,12 +307,21 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ { // 此处应该是要报错？
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			// -math.MinInt64 超出int64范围
+			return newInteger(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInt:
+		return newInteger(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default: // 此处应该是要报错？
 		//return NULL // 更新：是的
 		return newError("unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
@@ -197,8 +344,24 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 		// 对 于 *object.Integer，总是有新分配的object.Integer实例，也就是使⽤新的指针。⽽整数不
 		// 能通过⽐较不同的实例之间的指针来判断相等性，否则5 == 5将为false。这不是我们期望的⾏为。
 		return evalIntegerInfixExpression(operator, left, right)
+	case isInteger(left) && isInteger(right):
+		// 至少有一个是BigInt
+		return evalBigIntInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		// 至少有一个是Float，整数提升为浮点数后再计算
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
+	case operator == "*" && left.Type() == object.STRING_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalStringRepetition(left, right)
+	case operator == "*" && left.Type() == object.INTEGER_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringRepetition(right, left)
+	case left.Type() == object.ARRAY_OBJ && right.Type() == object.ARRAY_OBJ:
+		return evalArrayInfixExpression(operator, left, right)
+	case left.Type() == object.HASH_OBJ && right.Type() == object.HASH_OBJ && operator == "==":
+		return nativeBoolToBooleanObject(objectsEqual(left, right))
+	case left.Type() == object.HASH_OBJ && right.Type() == object.HASH_OBJ && operator == "!=":
+		return nativeBoolToBooleanObject(!objectsEqual(left, right))
 	case operator == "==":
 		// 直接对比object本身，其中包括了对比值与类型，这之所以可⾏，是因为程序中⼀直都在使⽤
 		// 指向对象的指针，⽽布尔值只有TRUE和FALSE两个对象。 这也适⽤于NULL，但不适用于整数或其他。
@@ -221,14 +384,70 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	leftValue := left.(*object.Integer).Value
 	rightValue := right.(*object.Integer).Value
 	switch operator {
+	case "+", "-", "*", "/", "%", "**", "<<":
+		if err := checkIntegerOperand(operator, rightValue); err != nil {
+			return err
+		}
+		if operator == "**" && rightValue < 0 {
+			// 负数次幂的结果不是整数
+			return &object.Float{Value: math.Pow(float64(leftValue), float64(rightValue))}
+		}
+		if result, ok := int64Arithmetic(operator, leftValue, rightValue); ok {
+			return &object.Integer{Value: result}
+		}
+		// 溢出时提升为BigInt再计算，保证结果正确
+		return evalBigIntInfixExpression(operator, left, right)
+	case ">>":
+		if err := checkIntegerOperand(operator, rightValue); err != nil {
+			return err
+		}
+		if rightValue > 63 {
+			rightValue = 63 // 算术右移，移出所有位后结果是0或-1
+		}
+		return &object.Integer{Value: leftValue >> rightValue}
+	case "&":
+		return &object.Integer{Value: leftValue & rightValue}
+	case "|":
+		return &object.Integer{Value: leftValue | rightValue}
+	case "^":
+		return &object.Integer{Value: leftValue ^ rightValue}
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue)
+	case ">":
+		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "<":
+		return nativeBoolToBooleanObject(leftValue < rightValue)
+	default:
+		//return NULL
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalFloatInfixExpression float的中缀表达式求值，int与float混合运算时int会先转换为float
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+	switch operator {
 	case "+":
-		return &object.Integer{Value: leftValue + rightValue}
+		return &object.Float{Value: leftValue + rightValue}
 	case "-":
-		return &object.Integer{Value: leftValue - rightValue}
+		return &object.Float{Value: leftValue - rightValue}
 	case "*":
-		return &object.Integer{Value: leftValue * rightValue}
+		return &object.Float{Value: leftValue * rightValue}
 	case "/":
-		return &object.Integer{Value: leftValue / rightValue}
+		// 与整数不同，浮点数除以0遵循IEEE 754，得到±Inf或NaN
+		return &object.Float{Value: leftValue / rightValue}
+	case "%":
+		// 与整数的 % 一样，结果的符号与被除数相同
+		return &object.Float{Value: math.Mod(leftValue, rightValue)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftValue, rightValue)}
 	case "==":
 		return nativeBoolToBooleanObject(leftValue == rightValue)
 	case "!=":
@@ -237,19 +456,152 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 		return nativeBoolToBooleanObject(leftValue > rightValue)
 	case "<":
 		return nativeBoolToBooleanObject(leftValue < rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
 	default:
-		//return NULL
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
+// evalStringInfixExpression 字符串的拼接与比较，比较按字节的字典序进行
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
-	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
-	}
 	leftValue := left.(*object.String).Value
 	rightValue := right.(*object.String).Value
-	return &object.String{Value: leftValue + rightValue}
+	switch operator {
+	case "+":
+		return &object.String{Value: leftValue + rightValue}
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue)
+	case "<":
+		return nativeBoolToBooleanObject(leftValue < rightValue)
+	case ">":
+		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalInterpolatedString 依次对各部分求值并拼接，字符串直接使用其值，其他值使用Inspect的结果
+func (in *Interpreter) evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out strings.Builder
+	for _, part := range node.Parts {
+		value := in.eval(part, env)
+		if isAbrupt(value) {
+			return value
+		}
+		if str, ok := value.(*object.String); ok {
+			out.WriteString(str.Value)
+		} else {
+			out.WriteString(value.Inspect())
+		}
+	}
+	return &object.String{Value: out.String()}
+}
+
+// evalStringRepetition 字符串重复，如 "ab" * 3 得到 "ababab"
+func evalStringRepetition(str, count object.Object) object.Object {
+	value := str.(*object.String).Value
+	n := count.(*object.Integer).Value
+	if n < 0 {
+		return newError("negative repeat count: %d", n)
+	}
+	// 先检查结果的长度再分配，len(value)*n 可能溢出，所以用除法比较
+	if len(value) > 0 && n > maxStringLength/int64(len(value)) {
+		return newError("repeated string too long: %d bytes * %d", len(value), n)
+	}
+	return &object.String{Value: strings.Repeat(value, int(n))}
+}
+
+// maxStringLength 字符串重复的结果的最大字节数，与 maxIntegerBits 一样，避免一个表达式就耗尽内存
+const maxStringLength = 1 << 28
+
+// evalArrayInfixExpression 数组的拼接与逐个元素的比较
+func evalArrayInfixExpression(operator string, left, right object.Object) object.Object {
+	leftElements := left.(*object.Array).Elements
+	rightElements := right.(*object.Array).Elements
+	switch operator {
+	case "+":
+		elements := make([]object.Object, 0, len(leftElements)+len(rightElements))
+		elements = append(elements, leftElements...)
+		elements = append(elements, rightElements...)
+		return &object.Array{Elements: elements}
+	case "==":
+		return nativeBoolToBooleanObject(objectsEqual(left, right))
+	case "!=":
+		return nativeBoolToBooleanObject(!objectsEqual(left, right))
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// objectsEqual 结构相等：数组长度相同且对应的元素都相等，哈希表的键相同且对应的值都相等（与插入顺序无关），
+// 其他值按 == 比较。可变数组和哈希表可能包含自身，所以需要记录正在比较的对象，避免无限递归
+func objectsEqual(left, right object.Object) bool {
+	return deepEqual(left, right, make(map[[2]object.Object]bool))
+}
+
+func deepEqual(left, right object.Object, visited map[[2]object.Object]bool) bool {
+	if left == right {
+		return true
+	}
+	switch left := left.(type) {
+	case *object.Array:
+		right, ok := right.(*object.Array)
+		if !ok || len(left.Elements) != len(right.Elements) {
+			return false
+		}
+		// 正在比较的一对对象再次出现，说明存在环，环上的其他部分会决定是否相等
+		pair := [2]object.Object{left, right}
+		if visited[pair] {
+			return true
+		}
+		visited[pair] = true
+		for i := range left.Elements {
+			if !deepEqual(left.Elements[i], right.Elements[i], visited) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		right, ok := right.(*object.Hash)
+		if !ok || len(left.Pairs) != len(right.Pairs) {
+			return false
+		}
+		pair := [2]object.Object{left, right}
+		if visited[pair] {
+			return true
+		}
+		visited[pair] = true
+		for _, leftPair := range left.Pairs {
+			rightPair, ok := right.Get(leftPair.Key.(object.Hashable))
+			if !ok || !deepEqual(leftPair.Value, rightPair.Value, visited) {
+				return false
+			}
+		}
+		return true
+	}
+	return evalInfixExpression("==", left, right) == TRUE
+}
+
+// evalLogicalExpression 短路求值 && 和 ||，结果是决定了真假的那个操作数本身，而不一定是布尔值
+// 如 false && x 不会对x求值，false || 1 得到 1，0 && 2 得到 2（0也是真值）
+func (in *Interpreter) evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := in.eval(node.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+	if node.Operator == "&&" && !isTrue(left) || node.Operator == "||" && isTrue(left) {
+		return left
+	}
+	return in.eval(node.Right, env)
 }
 
 /*
@@ -268,26 +620,26 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 		}
 	}
 */
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
-	if isError(condition) {
+func (in *Interpreter) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := in.eval(ie.Condition, env)
+	if isAbrupt(condition) {
 		return condition
 	}
 	if isTrue(condition) {
-		return Eval(ie.Consequence, env)
+		return in.eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return in.eval(ie.Alternative, env)
 	} else {
 		return NULL
 	}
 }
 
 // evalProgram P369-P370 较为重要 降低通用性
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+func (in *Interpreter) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range program.Statements {
 		// 对每个statement eval，
-		result = Eval(statement, env)
+		result = withPos(in.eval(statement, env), statement)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -299,23 +651,172 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result // 作为解释器，通常只返回最后一个语句的求值
 }
 
-// ?
-func evalBlockStatement(bs *ast.BlockStatement, env *object.Environment) object.Object {
+// evalBlockStatement tail为true表示块处于尾部位置，此时最后一条语句也处于尾部位置
+func (in *Interpreter) evalBlockStatement(bs *ast.BlockStatement, env *object.Environment, tail bool) object.Object {
+	if err := in.checkCancelled(); err != nil {
+		return withPos(err, bs)
+	}
 	var result object.Object
-	for _, statement := range bs.Statements {
-		result = Eval(statement, env)
+	for i, statement := range bs.Statements {
+		if tail && i == len(bs.Statements)-1 {
+			result = withPos(in.evalTail(statement, env), statement)
+		} else {
+			result = withPos(in.eval(statement, env), statement)
+		}
 		if result != nil {
 			resultType := result.Type()
-			// 是返回值，或有错误时，立刻返回
-			if resultType == object.RETURN_VALUE_OBJ || resultType == object.ERROR_OBJ {
+			// 是返回值、循环控制信号，或有错误时，立刻返回，由外层的函数调用或循环处理
+			switch resultType {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
 				return result
 			}
 		}
 	}
+	if result == nil {
+		// 空的块语句，或最后一条是let语句，块的值为NULL，避免外层表达式拿到nil
+		return NULL
+	}
 	return result
 
 }
 
+// evalTail 对处于尾部位置的结点求值：函数调用不会立即执行，而是返回 object.TailCall，
+// 由applyFunction在循环中执行（蹦床），这样尾递归不会增加宿主语言的调用栈
+// 尾部位置是return的值、函数体的最后一个表达式，以及处于尾部位置的if表达式的各个分支的最后一个表达式
+func (in *Interpreter) evalTail(node ast.Node, env *object.Environment) object.Object {
+	if err := in.step(); err != nil {
+		return withPos(err, node)
+	}
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		return in.evalTail(node.Expression, env)
+	case *ast.BlockStatement:
+		return in.evalBlockStatement(node, env, true)
+	case *ast.IfExpression:
+		condition := in.eval(node.Condition, env)
+		if isAbrupt(condition) {
+			return condition
+		}
+		if isTrue(condition) {
+			return in.evalTail(node.Consequence, env)
+		} else if node.Alternative != nil {
+			return in.evalTail(node.Alternative, env)
+		}
+		return NULL
+	case *ast.CallExpression:
+		function := in.eval(node.Function, env)
+		if isAbrupt(function) {
+			return function
+		}
+		args := in.evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isAbrupt(args[0]) {
+			return args[0]
+		}
+		return &object.TailCall{Function: function, Arguments: args, Call: node}
+	default:
+		return in.evalNode(node, env)
+	}
+}
+
+// evalWhileStatement 条件为真时重复执行循环体，循环本身的值为NULL
+func (in *Interpreter) evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := in.eval(ws.Condition, env)
+		if isAbrupt(condition) {
+			return condition
+		}
+		if !isTrue(condition) {
+			return NULL
+		}
+		if result, done := in.evalLoopBody(ws.Body, env); done {
+			return result
+		}
+	}
+}
+
+// evalForStatement 依次将可迭代对象的每个元素绑定到循环变量上并执行循环体
+// 循环变量与let一样定义在当前环境中，循环结束后仍然可以访问
+func (in *Interpreter) evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := in.eval(fs.Iterable, env)
+	if isAbrupt(iterable) {
+		return iterable
+	}
+	var result object.Object = NULL
+	// 字符串的字符和range的整数是遍历时新分配的
+	_, fresh := iterable.(*object.String)
+	if _, ok := iterable.(*object.Range); ok {
+		fresh = true
+	}
+	err := forEach(iterable, func(item object.Object) bool {
+		if fresh {
+			if item = in.allocate(item); isError(item) {
+				result = withPos(item, fs.Iterable)
+				return false
+			}
+		}
+		env.Set(fs.Variable.Value, item)
+		var done bool
+		result, done = in.evalLoopBody(fs.Body, env)
+		return !done
+	})
+	if err != nil {
+		return withPos(err, fs.Iterable)
+	}
+	return result
+}
+
+// evalLoopBody 执行一次循环体，done为true表示循环应当结束，此时result是整个循环的值
+func (in *Interpreter) evalLoopBody(body *ast.BlockStatement, env *object.Environment) (result object.Object, done bool) {
+	if err := in.checkCancelled(); err != nil {
+		return withPos(err, body), true
+	}
+	result = in.eval(body, env)
+	switch result.(type) {
+	case *object.Break:
+		return NULL, true
+	case *object.ReturnValue, *object.Error:
+		// return和错误穿过循环，继续向外传递
+		return result, true
+	}
+	return NULL, false
+}
+
+// forEach 遍历可迭代对象：数组的元素、字符串的字符、哈希表的键、range的整数，yield返回false时停止遍历
+func forEach(iterable object.Object, yield func(object.Object) bool) *object.Error {
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		// 每次都重新检查长度，循环体中修改数组的元素是可见的
+		for i := 0; i < len(iterable.Elements); i++ {
+			if !yield(iterable.Elements[i]) {
+				break
+			}
+		}
+	case *object.String:
+		for _, r := range iterable.Value {
+			if !yield(&object.String{Value: string(r)}) {
+				break
+			}
+		}
+	case *object.Hash:
+		// 按插入顺序遍历，循环体中新增的键不会出现在本次遍历中
+		for _, pair := range iterable.Pairs {
+			if !yield(pair.Key) {
+				break
+			}
+		}
+	case *object.Range:
+		n := iterable.Len()
+		for i := uint64(0); i < n; i++ {
+			if !yield(&object.Integer{Value: iterable.At(i)}) {
+				break
+			}
+		}
+	default:
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+	return nil
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	// 通过env查找标识符对应值
 	if val, ok := env.Get(node.Value); ok {
@@ -327,12 +828,101 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	return newError("identifier not found: " + node.Value)
 }
 
+// evalAssignExpression 对赋值表达式求值，返回赋值后的值
+func (in *Interpreter) evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		value := in.eval(node.Value, env)
+		if isAbrupt(value) {
+			return value
+		}
+		if node.Operator != "=" {
+			current, ok := env.Get(target.Value)
+			if !ok {
+				return newError("assignment to undefined variable: %s", target.Value)
+			}
+			value = in.evalInfix(strings.TrimSuffix(node.Operator, "="), current, value)
+			if isError(value) {
+				return value
+			}
+		}
+		if _, ok := env.Assign(target.Value, value); !ok {
+			return newError("assignment to undefined variable: %s", target.Value)
+		}
+		return value
+
+	case *ast.IndexExpression:
+		// 从左到右求值：被索引的对象、索引、右侧的值
+		container := in.eval(target.ArrayIdentifier, env)
+		if isAbrupt(container) {
+			return container
+		}
+		index := in.eval(target.Index, env)
+		if isAbrupt(index) {
+			return index
+		}
+		value := in.eval(node.Value, env)
+		if isAbrupt(value) {
+			return value
+		}
+		if node.Operator != "=" {
+			current := evalIndexExpression(container, index)
+			if isError(current) {
+				return current
+			}
+			value = in.evalInfix(strings.TrimSuffix(node.Operator, "="), current, value)
+			if isError(value) {
+				return value
+			}
+		}
+		return in.evalIndexAssignment(container, index, value)
+
+	default:
+		return newError("cannot assign to %s", node.Target.String())
+	}
+}
+
+// evalIndexAssignment 修改数组的元素或哈希表的键值对
+func (in *Interpreter) evalIndexAssignment(container, index, value object.Object) object.Object {
+	switch container := container.(type) {
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+		i, ok := normalizeIndex(idx.Value, int64(len(container.Elements)))
+		if !ok {
+			return newError("index out of range: %d (length %d)", idx.Value, len(container.Elements))
+		}
+		if container.Frozen {
+			return newError("cannot assign to element of immutable array")
+		}
+		container.Elements[i] = value
+		return value
+	case *object.Hash:
+		key, ok := object.AsHashable(index)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		if _, ok := container.Get(key); !ok {
+			// 新的键使哈希表变大
+			if err := in.charge(0, pairSize); err != nil {
+				return err
+			}
+		}
+		container.Set(key, value)
+		return value
+	default:
+		return newError("index assignment not supported: %s", container.Type())
+	}
+}
+
 // evalExpressions 对多个表达式求值，返回object列表，用于对函数调用的参数列表求值
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func (in *Interpreter) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 	for _, e := range exps {
-		evaluated := Eval(e, env)
-		if isError(evaluated) {
+		evaluated := in.eval(e, env)
+		if isAbrupt(evaluated) {
 			return []object.Object{evaluated}
 		}
 		result = append(result, evaluated)
@@ -345,6 +935,8 @@ func evalIndexExpression(identifier object.Object, index object.Object) object.O
 	switch {
 	case identifier.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(identifier, index)
+	case identifier.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(identifier, index)
 	case identifier.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(identifier, index)
 	default:
@@ -352,74 +944,294 @@ func evalIndexExpression(identifier object.Object, index object.Object) object.O
 	}
 }
 
+// normalizeIndex 负数索引从末尾开始计数，-1是最后一个元素，ok为false表示越界
+func normalizeIndex(idx, length int64) (int64, bool) {
+	if idx < 0 {
+		idx += length
+	}
+	return idx, idx >= 0 && idx < length
+}
+
+// evalArrayIndexExpression 数组索引，越界时为NULL
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObj := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObj.Elements) - 1)
-	if idx < 0 || idx > max {
+	idx, ok := normalizeIndex(index.(*object.Integer).Value, int64(len(arrayObj.Elements)))
+	if !ok {
 		return NULL
 	}
 	return arrayObj.Elements[idx]
 }
 
+// evalStringIndexExpression 字符串按字符（码点）索引，结果是只含一个字符的字符串，越界时为NULL
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	value := str.(*object.String).Value
+	idx := index.(*object.Integer).Value
+	if idx < 0 {
+		// 只有负数索引才需要知道字符的数量
+		idx += int64(utf8.RuneCountInString(value))
+		if idx < 0 {
+			return NULL
+		}
+	}
+	for _, r := range value {
+		if idx == 0 {
+			return &object.String{Value: string(r)}
+		}
+		idx--
+	}
+	return NULL
+}
+
+// evalSliceExpression 数组和字符串的切片 a[start:stop:step]，结果是新的数组或字符串
+// 与Python相同：负数从末尾开始计数，越界的start和stop会被截断到合法范围内，
+// step默认为1，为负数时倒序取元素，此时start和stop的默认值分别是末尾和开头
+func (in *Interpreter) evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	container := in.eval(node.ArrayIdentifier, env)
+	if isAbrupt(container) {
+		return container
+	}
+	var bounds [3]*int64 // start, stop, step，省略时为nil
+	for i, exp := range []ast.Expression{node.Start, node.Stop, node.Step} {
+		if exp == nil {
+			continue
+		}
+		bound := in.eval(exp, env)
+		if isAbrupt(bound) {
+			return bound
+		}
+		integer, ok := bound.(*object.Integer)
+		if !ok {
+			return withPos(newError("slice index must be INTEGER, got %s", bound.Type()), exp)
+		}
+		bounds[i] = &integer.Value
+	}
+	step := int64(1)
+	if bounds[2] != nil {
+		step = *bounds[2]
+		if step == 0 {
+			return withPos(newError("slice step must not be zero"), node.Step)
+		}
+	}
+
+	switch container := container.(type) {
+	case *object.Array:
+		start, n := sliceIndices(int64(len(container.Elements)), bounds[0], bounds[1], step)
+		if err := in.charge(1, objectSize+int64(n)*slotSize); err != nil {
+			return withPos(err, node)
+		}
+		elements := make([]object.Object, n)
+		for k := range elements {
+			elements[k] = container.Elements[start+int64(k)*step]
+		}
+		// 元组的切片仍然是元组
+		return &object.Array{Elements: elements, Frozen: container.Frozen}
+	case *object.String:
+		runes := []rune(container.Value)
+		start, n := sliceIndices(int64(len(runes)), bounds[0], bounds[1], step)
+		// 每个字符最多占utf8.UTFMax个字节
+		if err := in.charge(1, objectSize+int64(n)*utf8.UTFMax); err != nil {
+			return withPos(err, node)
+		}
+		result := make([]rune, n)
+		for k := range result {
+			result[k] = runes[start+int64(k)*step]
+		}
+		return &object.String{Value: string(result)}
+	default:
+		return withPos(newError("slice operator not supported: %s", container.Type()), node)
+	}
+}
+
+// sliceIndices 计算切片的第一个元素的下标和元素的数量，第k个元素的下标是 start + k*step
+func sliceIndices(length int64, startBound, stopBound *int64, step int64) (start int64, n int) {
+	// 越界的下标截断到 [lower, upper]，step为负数时下标可以取到-1，表示开头之前
+	lower, upper := int64(0), length
+	if step < 0 {
+		lower, upper = -1, length-1
+	}
+	clamp := func(bound *int64, def int64) int64 {
+		if bound == nil {
+			return def
+		}
+		i := *bound
+		if i < 0 {
+			i += length
+		}
+		if i < lower {
+			return lower
+		}
+		if i > upper {
+			return upper
+		}
+		return i
+	}
+	if step > 0 {
+		start = clamp(startBound, lower)
+		stop := clamp(stopBound, upper)
+		if start < stop {
+			n = int((stop-start-1)/step + 1)
+		}
+	} else {
+		start = clamp(startBound, upper)
+		stop := clamp(stopBound, lower)
+		if start > stop {
+			// -step 在 step 为 math.MinInt64 时会溢出，所以用无符号数计算
+			n = int(uint64(start-stop-1)/-uint64(step) + 1)
+		}
+	}
+	return start, n
+}
+
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
-	key, ok := index.(object.Hashable)
+	key, ok := object.AsHashable(index)
 	if !ok {
 		return newError("unusable as hash key: %s", index.Type())
 	}
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key)
 	if !ok {
 		return NULL
 	}
 	return pair.Value
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
-		if isError(key) {
+func (in *Interpreter) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+	// 按源码中的顺序依次对键和值求值
+	for _, pair := range node.Pairs {
+		key := in.eval(pair.Key, env)
+		if isAbrupt(key) {
 			return key
 		}
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.AsHashable(key)
 		if !ok {
 			return newError("unusable as hash key: %s", key.Type())
 		}
-		value := Eval(valueNode, env)
-		if isError(value) {
+		value := in.eval(pair.Value, env)
+		if isAbrupt(value) {
 			return value
 		}
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
-// applyFunction 根据参数列表args，对函数fn调用求值
-func applyFunction(fn object.Object, args []object.Object) object.Object {
-	switch fn := fn.(type) {
-	case *object.Function:
-		// 新建环境，即作用域
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv) // 为什么扩展的是定义函数时的环境，⽽不是当前环境？闭包
-		return unwrapReturnValue(evaluated)
-	case *object.Builtin:
-		return fn.Fn(args...)
-	default:
-		return newError("not a function: %s", fn.Type())
+// applyFunction 根据参数列表args，对函数fn调用求值，call是调用处，用于记录错误的位置和调用栈
+// 函数体返回尾调用时，在循环中接着执行被调用的函数（蹦床），而不是递归调用applyFunction
+func (in *Interpreter) applyFunction(fn object.Object, args []object.Object, call *ast.CallExpression) object.Object {
+	if in.MaxCallDepth > 0 && in.depth >= in.MaxCallDepth {
+		return withPos(newError("maximum call depth %d exceeded", in.MaxCallDepth), call)
 	}
+	in.depth++
+	defer func() { in.depth-- }()
 
+	var frames tailFrames
+	for {
+		if err := in.checkCancelled(); err != nil {
+			return frames.appendTo(withPos(err, call))
+		}
+		switch f := fn.(type) {
+		case *object.Function:
+			if err := checkArity(f, len(args)); err != nil {
+				return frames.appendTo(withPos(err, call))
+			}
+			// 新建环境，即作用域
+			extendedEnv, err := in.extendFunctionEnv(f, args)
+			if err != nil {
+				return frames.appendTo(withFrame(err, f, call)) // 默认值求值出错，出错位置在函数的参数列表中
+			}
+			evaluated := in.evalTail(f.Body, extendedEnv) // 为什么扩展的是定义函数时的环境，⽽不是当前环境？闭包
+			result := unwrapReturnValue(evaluated)
+			if tc, ok := result.(*object.TailCall); ok {
+				frames.push(f, call)
+				fn, args, call = tc.Function, tc.Arguments, tc.Call
+				continue
+			}
+			return frames.appendTo(withFrame(result, f, call))
+		case *object.Builtin:
+			return frames.appendTo(withPos(f.Fn(in, args...), call))
+		default:
+			return frames.appendTo(withPos(newError("not a function: %s", fn.Type()), call))
+		}
+	}
 }
 
-// extendFunctionEnv 新建环境，将参数列表args存入
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// maxTailFrames 最多记录的被尾调用省略的函数帧数量
+const maxTailFrames = 100
+
+// tailFrames 被尾调用省略的函数帧，出错时仍然记录到调用栈中
+// 为了让尾递归只占用常数的空间，连续相同的帧只记录一次，超过maxTailFrames时丢弃最外层的帧
+type tailFrames []tailFrame
+
+type tailFrame struct {
+	fn   *object.Function
+	call *ast.CallExpression
+}
+
+// push 记录一个被省略的帧，它比已记录的帧都更靠内层
+func (tf *tailFrames) push(fn *object.Function, call *ast.CallExpression) {
+	frames := *tf
+	if n := len(frames); n > 0 && frames[n-1].fn == fn && frames[n-1].call == call {
+		return
+	}
+	if len(frames) == maxTailFrames {
+		frames = append(frames[:0], frames[1:]...)
+	}
+	*tf = append(frames, tailFrame{fn: fn, call: call})
+}
+
+// appendTo 将被省略的帧按从内到外的顺序加到错误的调用栈中
+func (tf tailFrames) appendTo(obj object.Object) object.Object {
+	for i := len(tf) - 1; i >= 0; i-- {
+		obj = withFrame(obj, tf[i].fn, tf[i].call)
+	}
+	return obj
+}
+
+// checkArity 检查实参数量是否与函数的参数列表相符
+func checkArity(fn *object.Function, got int) *object.Error {
+	min, max := fn.Arity()
+	if got >= min && (max == -1 || got <= max) {
+		return nil
+	}
+	switch {
+	case max == -1:
+		return newError("wrong number of arguments. got=%d, want>=%d", got, min)
+	case min == max:
+		return newError("wrong number of arguments. got=%d, want=%d", got, min)
+	default:
+		return newError("wrong number of arguments. got=%d, want=%d..%d", got, min, max)
+	}
+}
+
+// extendFunctionEnv 新建环境，将参数列表args存入，调用前需要用checkArity保证参数数量正确
+// 缺少的实参使用默认值，默认值在新环境中求值，因此可以引用前面的参数，如 fn(x, y = x * 2)
+func (in *Interpreter) extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, object.Object) {
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+			continue
+		}
+		value := in.eval(fn.Defaults[paramIdx], env)
+		if isAbrupt(value) {
+			return nil, value
+		}
+		env.Set(param.Value, value)
 	}
-	return env
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		restArray := in.allocate(&object.Array{Elements: rest})
+		if isError(restArray) {
+			return nil, restArray
+		}
+		env.Set(fn.Rest.Value, restArray)
+	}
+	return env, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -434,6 +1246,32 @@ func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)} // a...
 }
 
+// withPos 为还没有位置的错误记录出错结点的位置，已有位置的错误来自更内层的结点，保持不变
+func withPos(obj object.Object, node ast.Node) object.Object {
+	if err, ok := obj.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+	return obj
+}
+
+// withFrame 错误从用户定义的函数中传出时，在调用栈中记录该函数及其调用处
+func withFrame(obj object.Object, fn *object.Function, call *ast.CallExpression) object.Object {
+	if err, ok := obj.(*object.Error); ok {
+		err.Stack = append(err.Stack, object.Frame{Function: fn.Signature(), Pos: call.Pos()})
+	}
+	return obj
+}
+
+// isAbrupt 是否是需要立刻向外传递的结果：错误、return的值或循环控制信号
+// 它们可能从表达式中的if块里产生，如 let y = if (c) { break }，不能被当作普通的值使用
+func isAbrupt(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Error, *object.ReturnValue, *object.Break, *object.Continue:
+		return true
+	}
+	return false
+}
+
 func isError(obj object.Object) bool {
 	if obj != nil {
 		return obj.Type() == object.ERROR_OBJ
@@ -441,6 +1279,38 @@ func isError(obj object.Object) bool {
 	return false
 }
 
+// isNumber 是否是可以参与算术运算的数字
+func isNumber(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.BigInt, *object.Float:
+		return true
+	}
+	return false
+}
+
+// isInteger 是否是整数，包括Integer和BigInt
+func isInteger(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.BigInt:
+		return true
+	}
+	return false
+}
+
+// toFloat 将数字转换为float64，调用前需要保证isNumber(obj)
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInt:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f
+	case *object.Float:
+		return obj.Value
+	}
+	return 0
+}
+
 func nativeBoolToBooleanObject(b bool) object.Object {
 	if b {
 		return TRUE
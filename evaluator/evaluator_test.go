@@ -4,7 +4,12 @@ import (
 	"Monkey_1/lexer"
 	"Monkey_1/object"
 	"Monkey_1/parser"
+	"context"
+	"errors"
+	"math"
+	"runtime/debug"
 	"testing"
+	"time"
 )
 
 func testEval(input string) object.Object {
@@ -50,6 +55,258 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xFF", 255},
+		{"0xff & 0x0F", 15},
+		{"0o755", 493},
+		{"0b1010", 10},
+		{"1_000_000", 1000000},
+		{"0b1111_0000 | 0b1111", 255},
+		{"-0x10", -16},
+		// 没有进制前缀的都是十进制，前导0不表示八进制
+		{"010", 10},
+		{"0755", 755},
+		{"09", 9},
+		{"0_9", 9},
+		{"00", 0},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+	testFloatObject(t, testEval("1_000.5"), 1000.5)
+	if big := testEval("0xFFFF_FFFF_FFFF_FFFF_FF"); big.Inspect() != "4722366482869645213695" {
+		t.Errorf("wrong big integer. got=%s", big.Inspect())
+	}
+	if big := testEval("099999999999999999999"); big.Inspect() != "99999999999999999999" {
+		t.Errorf("wrong big integer. got=%s", big.Inspect())
+	}
+}
+
+func TestEvalBigIntExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		isBig    bool // 在int64范围内的结果总是Integer
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808", true},
+		{"-9223372036854775807 - 2", "-9223372036854775809", true},
+		{"9223372036854775807 * 9223372036854775807", "85070591730234615847396907784232501249", true},
+		{"-9223372036854775807 - 1", "-9223372036854775808", false},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808", true},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808", true},
+		{"99999999999999999999999", "99999999999999999999999", true},
+		{"-99999999999999999999999", "-99999999999999999999999", true},
+		{"99999999999999999999999 - 99999999999999999999998", "1", false},
+		{"(9223372036854775807 + 1) - 1", "9223372036854775807", false},
+		{"100000000000000000000 / 3", "33333333333333333333", true},
+		{"4294967296 * 4294967296", "18446744073709551616", true},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong value. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+		if _, isBig := evaluated.(*object.BigInt); isBig != tt.isBig {
+			t.Errorf("%s: wrong integer type. got=%T", tt.input, evaluated)
+		}
+	}
+}
+
+func TestBigIntComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"9223372036854775807 + 1 > 9223372036854775807", true},
+		{"99999999999999999999999 == 99999999999999999999999", true},
+		{"99999999999999999999999 < 1", false},
+		{"99999999999999999999999 != 1", true},
+		{"99999999999999999999999 > 1.5", true},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestIntegerOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7 % -3", 1},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"-1 & 255", 255},
+		{"1 << 10", 1024},
+		{"1024 >> 3", 128},
+		{"-16 >> 2", -4},
+		{"-1 >> 100", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"(-2) ** 3", -8},
+		{"5 ** 0", 1},
+		{"2 ** -1", 0.5},
+		{"7.5 % 2", 1.5},
+		{"2.0 ** 3", 8.0},
+		{"4 ** 0.5", 2.0},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"2 >= 3", false},
+		{"2 >= 2", true},
+		{"1.5 >= 1", true},
+		{"1 <= 0.5", false},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestBigIntOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 ** 64", "18446744073709551616"},
+		{"1 << 64", "18446744073709551616"},
+		{"3 << 62", "13835058055282163712"},
+		{"(2 ** 64) >> 1", "9223372036854775808"},
+		{"(2 ** 64) >> 64", "1"},
+		{"(2 ** 70) >> 2000000", "0"},
+		{"-(2 ** 70) >> 2000000", "-1"},
+		{"(2 ** 70) >> 2 ** 64", "0"},
+		{"1 >> 5000000", "0"},
+		{"1 ** (2 ** 62)", "1"},
+		{"(-1) ** (2 ** 62 + 1)", "-1"},
+		{"(2 ** 64 + 5) % 2 ** 64", "5"},
+		{"(2 ** 64 + 5) & 7", "5"},
+		{"(2 ** 64) | 1", "18446744073709551617"},
+		{"(2 ** 64) ^ (2 ** 64)", "0"},
+		{"(2 ** 64) ** 2", "340282366920938463463374607431768211456"},
+		{"-(2 ** 63)", "-9223372036854775808"},
+		{"2 ** 64 >= 2 ** 64", "true"},
+		{"2 ** 64 <= 1", "false"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got=%T(%s)", tt.input, tt.expected, evaluated, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStringAndArrayOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"abc" == "abc"`, true},
+		{`"abc" != "abd"`, true},
+		{`"abc" < "abd"`, true},
+		{`"b" > "abc"`, true},
+		{`"ab" < "abc"`, true},
+		{`"ab" <= "ab"`, true},
+		{`"Z" >= "a"`, false},
+		{`"ab" * 3`, "ababab"},
+		{`2 * "xy"`, "xyxy"},
+		{`"ab" * 0`, ""},
+		{`[1, 2] + [3]`, "[1,2,3]"},
+		{`[] + []`, "[]"},
+		{`[1, "a", [true]] == [1, "a", [true]]`, true},
+		{`[1, 2] == [1, 2, 3]`, false},
+		{`[1, 2] != [1, 3]`, true},
+		{`[1, 2.0] == [1.0, 2]`, true},
+		{`[1] == ["1"]`, false},
+		{`let a = [1]; let b = a + [2]; len(a);`, "1"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("%q: expected %q, got=%T(%s)", tt.input, expected, evaluated, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"2.5", 2.5},
+		{"-2.5", -2.5},
+		{"1e3", 1000},
+		{"0.1 + 0.2 * 2", 0.5},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"7 / 2.0", 3.5},
+		{"(1 + 2 + 4) / 2.0", 3.5},
+		{"3 * 1.5 - 1", 3.5},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestFloatComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1 == 1.0", true},
+		{"1.0 != 1", false},
+		{"0.1 + 0.2 == 0.3", false},
+		{"2.5 > 2.5", false},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2.0", "2.0"},
+		{"4 / 2.0", "2.0"},
+		{"0.25", "0.25"},
+		{"1e21", "1e+21"},
+		{"-0.5", "-0.5"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect(). expected=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	test := []struct {
 		input    string
@@ -104,6 +361,47 @@ func TestBangOperator(t *testing.T) {
 }
 
 // ##############################################
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		// 返回决定结果的操作数本身
+		{"1 && 2", 2},
+		{"0 || 2", 0},
+		{"false || 5", 5},
+		{"if (false) { 1 } || 3", 3},
+		{"if (false) { 1 } && 3", nil},
+		// 短路：右侧不会被求值，否则会得到错误
+		{"false && undefinedName", false},
+		{"true || undefinedName", true},
+		{"let n = 0; let inc = fn() { n += 1; true }; false && inc(); true || inc(); n;", 0},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+
+	evaluated := testEval("true && undefinedName")
+	if errObj, ok := evaluated.(*object.Error); !ok || errObj.Message != "identifier not found: undefinedName" {
+		t.Errorf("expected error for evaluated right operand. got=%T(%+v)", evaluated, evaluated)
+	}
+}
+
 func TestIfElseExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -209,72 +507,771 @@ return 1;
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			"1.5 + true",
+			"type mismatch: FLOAT + BOOLEAN",
+		},
+		{
+			"99999999999999999999999 + true",
+			"type mismatch: BIGINT + BOOLEAN",
+		},
+		{
+			"-true + 1.5",
+			"unknown operator: -BOOLEAN",
+		},
+		{
+			"5 % 0",
+			"modulo by zero",
+		},
+		{
+			"(2 ** 64) % 0",
+			"modulo by zero",
+		},
+		{
+			"1 << -1",
+			"negative shift count: -1",
+		},
+		{
+			"1 >> -1",
+			"negative shift count: -1",
+		},
+		{
+			"1 << 2 ** 64",
+			"shift count too large: 18446744073709551616",
+		},
+		{
+			"2 ** 2 ** 64",
+			"exponent too large: 18446744073709551616",
+		},
+		{
+			"(2 ** 1048576) ** 1048576",
+			"exponent too large: 1048576",
+		},
+		{
+			"(2 ** 70) << 20000000",
+			"shift count too large: 20000000",
+		},
+		{
+			"let x = 2 ** 8000000; x * x * x",
+			"integer result too large: 24000002 bits",
+		},
+		{
+			"1.5 & 1",
+			"unknown operator: FLOAT & INTEGER",
+		},
+		{
+			`"a" - "b"`,
+			"unknown operator: STRING - STRING",
+		},
+		{
+			`"a" * -1`,
+			"negative repeat count: -1",
+		},
+		{
+			`"abc" * 1000000000`,
+			"repeated string too long: 3 bytes * 1000000000",
+		},
+		{
+			`9223372036854775807 * "ab"`,
+			"repeated string too long: 2 bytes * 9223372036854775807",
+		},
+		{
+			"[1] - [1]",
+			"unknown operator: ARRAY - ARRAY",
+		},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok { // 表示出错后，代码没有停止
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestRuntimeFaults(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+		expectedPos     string
+	}{
+		{"1 / 0", "division by zero", "1:1"},
+		{"let x = 0; 10 / x", "division by zero", "1:12"},
+		{"99999999999999999999999 / 0", "division by zero", "1:1"},
+		{"let f = fn(x, y) { x + y }; f(1)", "wrong number of arguments. got=1, want=2", "1:29"},
+		{"fn() { 1 }(1, 2)", "wrong number of arguments. got=2, want=0", "1:1"},
+		{"fn(){}() + 1", "type mismatch: NULL + INTEGER", "1:1"},
+		{"let f = fn(){ let a = 1; }; f() * 2", "type mismatch: NULL * INTEGER", "1:29"},
+		{"boom(1)", "internal error: boom", ""},
+	}
+
+	builtins["boom"] = &object.Builtin{Fn: func(alloc object.Allocator, args ...object.Object) object.Object {
+		panic("boom")
+	}}
+	defer delete(builtins, "boom")
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, tt.expectedMessage, errObj.Message)
+		}
+		if tt.expectedPos != "" && errObj.Pos.String() != tt.expectedPos {
+			t.Errorf("%s: wrong error position. expected=%s, got=%s", tt.input, tt.expectedPos, errObj.Pos)
+		}
+		if len(errObj.Stack) != 0 {
+			t.Errorf("%s: unexpected stack. got=%+v", tt.input, errObj.Stack)
+		}
+	}
+}
+
+func TestFloatDivisionByZero(t *testing.T) {
+	evaluated := testEval("1.0 / 0")
+	result, ok := evaluated.(*object.Float)
+	if !ok {
+		t.Fatalf("object is not Float. got=%T (%+v)", evaluated, evaluated)
+	}
+	if !math.IsInf(result.Value, 1) {
+		t.Errorf("expected +Inf. got=%g", result.Value)
+	}
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	input := `let inner = fn(x) {
+  x + y
+};
+let outer = fn() { inner(1) };
+let value = fn(f) { f() }(outer);`
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "identifier not found: y" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+	if errObj.Pos.String() != "2:7" {
+		t.Errorf("wrong error position. got=%s", errObj.Pos)
+	}
+	expectedFrames := []struct {
+		function string
+		pos      string
+	}{
+		{"inner", "4:20"},
+		{"outer", "5:21"},
+		{"fn(f)", "5:13"},
+	}
+	if len(errObj.Stack) != len(expectedFrames) {
+		t.Fatalf("wrong number of frames. expected=%d, got=%d (%+v)",
+			len(expectedFrames), len(errObj.Stack), errObj.Stack)
+	}
+	for i, expected := range expectedFrames {
+		frame := errObj.Stack[i]
+		if frame.Function != expected.function || frame.Pos.String() != expected.pos {
+			t.Errorf("frame[%d] wrong. expected=%s at %s, got=%s at %s",
+				i, expected.function, expected.pos, frame.Function, frame.Pos)
+		}
+	}
+	expectedTrace := `ERROR: identifier not found: y
+    at inner (2:7)
+    at outer (4:20)
+    at fn(f) (5:21)
+    at <program> (5:13)`
+	if errObj.Trace() != expectedTrace {
+		t.Errorf("wrong trace. expected=\n%s\ngot=\n%s", expectedTrace, errObj.Trace())
+	}
+}
+
+// ##############################################
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+// ##############################################
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a = 10; a;", 10},
+		{"let a = 5; a = a + 1;", 6},
+		{"let a = 1; let b = 2; a = b = 3; a + b;", 6},
+		{"let a = 5; a += 2; a;", 7},
+		{"let a = 5; a -= 2; a;", 3},
+		{"let a = 5; a *= 2; a;", 10},
+		{"let a = 5; a /= 2; a;", 2},
+		{"let a = 1; let f = fn() { a = 2; }; f(); a;", 2},
+		{"let counter = fn() { let c = 0; fn() { c += 1; } }; let next = counter(); next(); next(); next();", 3},
+		{"let a = 1; let f = fn(a) { a = 5; }; f(1); a;", 1},
+		{"let arr = [1, 2, 3]; arr[1] = 20; arr[1];", 20},
+		{"let arr = [1, 2, 3]; arr[2] *= 5; arr[2];", 15},
+		{"let h = {\"a\": 1}; h[\"b\"] = 2; h[\"a\"] + h[\"b\"];", 3},
+		{"let h = {\"a\": 1}; h[\"a\"] += 9; h[\"a\"];", 10},
+		{"let a = 1; let arr = [a]; a = 2; arr[0];", 1},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestCyclicContainerInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = [1]; a[0] = a; a`, "[[...]]"},
+		{`let a = [1, 2]; a[1] = a; "${a}"`, "[1,[...]]"},
+		{`let h = {}; h["s"] = h; "${h}"`, `{s: {...}}`},
+		{`let a = [1]; let h = {"a": a}; a[0] = h; h`, `{a: [{...}]}`},
+		{`let a = [1]; [a, a]`, "[[1],[1]]"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestAssignErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"x = 1;", "assignment to undefined variable: x"},
+		{"x += 1;", "assignment to undefined variable: x"},
+		{"let a = 1; a += true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"let arr = [1]; arr[1] = 2;", "index out of range: 1 (length 1)"},
+		{"let arr = [1]; arr[-2] = 2;", "index out of range: -2 (length 1)"},
+		{"let arr = [1]; arr[\"a\"] = 2;", "array index must be INTEGER, got STRING"},
+		{"let h = {}; h[fn(x) { x }] = 1;", "unusable as hash key: FUNCTION"},
+		{"let s = \"abc\"; s[0] = \"x\";", "index assignment not supported: STRING"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 10) { i += 1; } i;", 10},
+		{"let i = 0; while (false) { i += 1; } i;", 0},
+		{"let i = 0; while (true) { i += 1; if (i == 5) { break; } } i;", 5},
+		{"let i = 0; let sum = 0; while (i < 10) { i += 1; if (i / 2 * 2 == i) { continue; } sum += i; } sum;", 25},
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x; } sum;", 6},
+		{"let sum = 0; for (x in range(5)) { sum += x; } sum;", 10},
+		{"let sum = 0; for (x in range(2, 5)) { sum += x; } sum;", 9},
+		{"let sum = 0; for (x in range(10, 0, -3)) { sum += x; } sum;", 22},
+		{"let sum = 0; for (x in range(0, 10, 4)) { sum += x; } sum;", 12},
+		{"let n = 0; for (x in range(5, 0)) { n += 1; } n;", 0},
+		{"let s = \"\"; for (c in \"abc\") { s = c + s; } s;", "cba"},
+		{"let sum = 0; for (k in {1: \"a\", 2: \"b\"}) { sum += k; } sum;", 3},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { break; } sum += x; } sum;", 3},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { continue; } sum += x; } sum;", 7},
+		{"let n = 0; for (i in range(3)) { for (j in range(3)) { if (j == 1) { break; } n += 1; } } n;", 3},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10; } } 0 }; f();", 20},
+		{"let f = fn() { while (true) { return 7; } }; f();", 7},
+		{"for (x in [1, 2]) { x } x;", 2},
+		{"let i = 0; while (i < 100000) { i += 1; } i;", 100000},
+		{"while (false) { 1 }", nil},
+		{"len(range(0, 10, 3))", 4},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("%q: expected %q, got=%T(%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestLoopControlInExpressions(t *testing.T) {
+	// break和continue从表达式中的if块里产生时，要穿过表达式传递到循环，而不是被当作普通的值
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let i = 0; while (true) { i += 1; let y = if (true) { break }; } i;", 1},
+		{"let n = 0; for (x in [1, 2, 3]) { n += 1; len(if (true) { break }) } n;", 1},
+		{"let n = 0; for (x in [1, 2, 3]) { n += 1; [1, if (true) { break }] } n;", 1},
+		{"let n = 0; for (x in [1, 2, 3]) { n += 1; {\"a\": if (true) { break }} } n;", 1},
+		{"let n = 0; for (x in [1, 2, 3]) { n += 1; {if (true) { break }: 1} } n;", 1},
+		{"let n = 0; for (x in [1, 2, 3]) { n += 1; [1][if (true) { break }] } n;", 1},
+		{"let n = 0; for (x in [1, 2, 3]) { n += 1; (if (true) { break })[0] } n;", 1},
+		{"let n = 0; for (x in [1, 2, 3]) { n += 1; 1 + if (true) { break } } n;", 1},
+		{"let n = 0; for (x in [1, 2, 3]) { n += 1; (if (true) { break }) + 1 } n;", 1},
+		{"let n = 0; for (x in [1, 2, 3]) { n += 1; -if (true) { break } } n;", 1},
+		{"let n = 0; for (x in [1, 2, 3]) { n += 1; n = if (true) { break }; } n;", 1},
+		{"let n = 0; for (x in [1, 2, 3]) { let y = if (x < 3) { continue }; n += 10; } n;", 10},
+		{"let n = 0; for (x in [1, 2, 3]) { n += x; puts(if (true) { continue }); n += 100; } n;", 6},
+		// return从表达式中的if块里产生时同样直接结束函数
+		{"let f = fn() { let y = if (true) { return 5 }; 10 }; f();", 5},
+		{"let f = fn() { 1 + if (true) { return 5 } }; f();", 5},
+	}
+	for _, tt := range tests {
+		// 限制步数，信号没有正确传递时循环不会结束
+		in := &Interpreter{MaxSteps: 100000}
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		testIntegerObject(t, in.Eval(program, object.NewEnvironment()), tt.expected)
+	}
+}
+
+func TestLoopErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"for (x in 5) { x }", "cannot iterate over INTEGER"},
+		{"for (x in [1, true]) { x + 1 }", "type mismatch: BOOLEAN + INTEGER"},
+		{"while (y) { 1 }", "identifier not found: y"},
+		{"range(0, 10, 0)", "range step must not be zero"},
+		{"range(\"a\")", "argument to `range` must be INTEGER, got STRING"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "fn(x) { x + 2; };"
+	evaluated := testEval(input)
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
+	}
+	if len(fn.Parameters) != 1 {
+		t.Fatalf("function has wrong parameters. Parameters=%+v",
+			fn.Parameters)
+	}
+	if fn.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", fn.Parameters[0])
+	}
+	expectedBody := "(x+2)"
+	if fn.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, fn.Body.String())
+	}
+}
+
+// ##############################################
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	// 限制宿主语言的调用栈，没有尾调用优化时以下递归会因栈溢出而崩溃
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let countdown = fn(n) { if (n == 0) { 0 } else { countdown(n - 1) } }; countdown(100000)`, 0},
+		{`let countdown = fn(n) { if (n == 0) { return 0; } return countdown(n - 1); }; countdown(100000)`, 0},
+		{`let sum = fn(n, acc) { if (n == 0) { return acc; } sum(n - 1, acc + n) }; sum(100000, 0)`, 5000050000},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+		  let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+		  even(100001)`, false},
+		{`let f = fn(n) { while (true) { if (n == 0) { return "done"; } return f(n - 1); } }; f(100000)`, "done"},
+		{`let f = fn(n) { if (n > 0) { if (n % 2 == 0) { f(n - 1) } else { f(n - 2) } } else { n } }; f(100001)`, -1},
+		{`let f = fn(n) { if (n == 0) { len } else { f(n - 1) } }; f(3)("abc")`, 3},
+		{`let f = fn(s) { len(s) }; f("hello")`, 5},
+		{`let f = fn(x) { x * 2 }; return f(21);`, 42},
+		{`let adder = fn(x) { fn(y) { x + y } }; let g = fn(n) { adder(n)(1) }; g(41)`, 42},
+		// 不在尾部位置的递归仍然正常工作
+		{`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)`, 610},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("%s: expected %q, got=%s", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestTailCallErrorStack(t *testing.T) {
+	input := `let inner = fn(n) { n + true };
+let loop = fn(n) { if (n == 0) { inner(n) } else { loop(n - 1) } };
+loop(100000)`
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	// 连续相同的尾调用帧只记录一次
+	expectedTrace := `ERROR: type mismatch: INTEGER + BOOLEAN
+    at inner (1:21)
+    at loop (2:34)
+    at loop (2:52)
+    at <program> (3:1)`
+	if errObj.Trace() != expectedTrace {
+		t.Errorf("wrong trace. expected=\n%s\ngot=\n%s", expectedTrace, errObj.Trace())
+	}
+
+	input = `let even = fn(n) { if (n == 0) { 1 + true } else { odd(n - 1) } };
+let odd = fn(n) { even(n - 1) };
+even(1000)`
+	evaluated = testEval(input)
+	errObj, ok = evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if len(errObj.Stack) != maxTailFrames+1 {
+		t.Errorf("wrong number of frames. expected=%d, got=%d", maxTailFrames+1, len(errObj.Stack))
+	}
+}
+
+func TestMaxCallDepth(t *testing.T) {
+	evalWith := func(in *Interpreter, input string) object.Object {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		return in.Eval(program, object.NewEnvironment())
+	}
+
+	// 默认的解释器也有深度限制，无穷递归不会导致宿主进程崩溃
+	evaluated := testEval(`let f = fn(n) { f(n + 1) + 1 }; f(0)`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "maximum call depth 10000 exceeded" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+	if len(errObj.Stack) != DefaultMaxCallDepth {
+		t.Errorf("wrong number of frames. expected=%d, got=%d", DefaultMaxCallDepth, len(errObj.Stack))
+	}
+	expectedTrace := `ERROR: maximum call depth 10000 exceeded
+    at f (1:17)
+    ... previous line repeated 9999 more times
+    at <program> (1:33)`
+	if errObj.Trace() != expectedTrace {
+		t.Errorf("wrong trace. expected=\n%s\ngot=\n%s", expectedTrace, errObj.Trace())
+	}
+
+	in := &Interpreter{MaxCallDepth: 50}
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(49)`, 49},
+		{`let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(50)`, "maximum call depth 50 exceeded"},
+		// 出错后深度被重置，解释器可以继续使用
+		{`let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(10)`, 10},
+		// 尾调用不增加深度
+		{`let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(1000)`, 0},
+		// 默认值中的调用也计入深度
+		{`let f = fn(n, x = f(n - 1)) { n }; f(100)`, "maximum call depth 50 exceeded"},
 	}
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := evalWith(in, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+
+	// 不大于0时不限制深度
+	unlimited := &Interpreter{}
+	evaluated = evalWith(unlimited, `let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(20000)`)
+	testIntegerObject(t, evaluated, 20000)
+}
+
+func TestEvalContext(t *testing.T) {
+	evalContext := func(ctx context.Context, input string) object.Object {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		return EvalContext(ctx, program, object.NewEnvironment())
+	}
+
+	runaway := []string{
+		`while (true) {}`,
+		`for (i in range(9223372036854775807)) {}`,
+		`let f = fn() { f() }; f()`,
+		`let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; while (true) { f(100) }`,
+	}
+	for _, input := range runaway {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		evaluated := evalContext(ctx, input)
+		cancel()
 		errObj, ok := evaluated.(*object.Error)
-		if !ok { // 表示出错后，代码没有停止
-			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", input, evaluated, evaluated)
 			continue
 		}
-		if errObj.Message != tt.expectedMessage {
-			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		if !errors.Is(errObj, context.DeadlineExceeded) {
+			t.Errorf("%s: error is not a deadline error. got=%q", input, errObj.Message)
 		}
+		if errObj.Message != "evaluation cancelled: context deadline exceeded" {
+			t.Errorf("%s: wrong error message. got=%q", input, errObj.Message)
+		}
+	}
+
+	// 已经取消的ctx，在第一个块语句处就停止
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	evaluated := evalContext(ctx, `let x = 1; if (true) { x = 2 }; x`)
+	if errObj, ok := evaluated.(*object.Error); !ok || !errors.Is(errObj, context.Canceled) {
+		t.Errorf("expected cancellation error. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	// 其他运行时错误没有Cause
+	evaluated = evalContext(context.Background(), `1 + true`)
+	if errObj, ok := evaluated.(*object.Error); !ok || errObj.Cause != nil || errors.Is(errObj, context.Canceled) {
+		t.Errorf("unexpected cause for runtime error. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	// 没有被取消时结果与Eval相同，取消之后解释器仍然可以继续使用
+	in := New()
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	program := parser.New(lexer.New(`let f = fn(n) { n * 2 }; f(21)`)).ParseProgram()
+	if !isError(in.EvalContext(ctx, program, object.NewEnvironment())) {
+		t.Errorf("expected cancellation error")
 	}
+	testIntegerObject(t, in.EvalContext(context.Background(), program, object.NewEnvironment()), 42)
+	testIntegerObject(t, in.Eval(program, object.NewEnvironment()), 42)
 }
 
-// ##############################################
-func TestLetStatements(t *testing.T) {
+func TestNestedEval(t *testing.T) {
+	// 内置函数中用同一个解释器求值，返回后外层的ctx和调用深度仍然有效
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	builtins["nested"] = &object.Builtin{Fn: func(alloc object.Allocator, args ...object.Object) object.Object {
+		program := parser.New(lexer.New(args[0].(*object.String).Value)).ParseProgram()
+		result := alloc.(*Interpreter).Eval(program, object.NewEnvironment())
+		cancel()
+		return result
+	}}
+	defer delete(builtins, "nested")
+
+	in := &Interpreter{MaxCallDepth: 100, MaxSteps: 100000}
+	program := parser.New(lexer.New(`let f = fn(n) { if (n == 0) { nested("1 + 1") } else { f(n - 1) + 0 } };
+let x = f(50);
+while (true) {}`)).ParseProgram()
+	evaluated := in.EvalContext(ctx, program, object.NewEnvironment())
+	if errObj, ok := evaluated.(*object.Error); !ok || !errors.Is(errObj, context.Canceled) {
+		t.Errorf("expected cancellation error. got=%T(%+v)", evaluated, evaluated)
+	}
+	if in.depth != 0 || in.ctx != nil || in.done != nil {
+		t.Errorf("interpreter state not restored. depth=%d, ctx=%v", in.depth, in.ctx)
+	}
+
+	// 嵌套的求值计入外层的执行预算
+	in = &Interpreter{MaxSteps: 1000}
+	program = parser.New(lexer.New(`nested("while (true) {}")`)).ParseProgram()
+	evaluated = in.EvalContext(context.Background(), program, object.NewEnvironment())
+	if errObj, ok := evaluated.(*object.Error); !ok || !errors.Is(errObj, ErrStepLimitExceeded) {
+		t.Errorf("expected step limit error. got=%T(%+v)", evaluated, evaluated)
+	}
+	if usage := in.Usage(); usage.Steps != 1001 {
+		t.Errorf("nested steps not counted. got=%d", usage.Steps)
+	}
+}
+
+func TestExecutionBudgets(t *testing.T) {
 	tests := []struct {
+		in       *Interpreter
 		input    string
-		expected int64
+		expected string
+		cause    error
 	}{
-		{"let a = 5; a;", 5},
-		{"let a = 5 * 5; a;", 25},
-		{"let a = 5; let b = a; b;", 5},
-		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{&Interpreter{MaxSteps: 1000}, `while (true) {}`, "step limit 1000 exceeded", ErrStepLimitExceeded},
+		{&Interpreter{MaxSteps: 1000}, `let f = fn() { f() }; f()`, "step limit 1000 exceeded", ErrStepLimitExceeded},
+		{&Interpreter{MaxAllocations: 1000}, `let a = []; while (true) { a = push(a, 1) }`,
+			"allocation limit 1000 objects exceeded", ErrAllocLimitExceeded},
+		{&Interpreter{MaxAllocations: 1000}, `for (c in range(100000)) {}`,
+			"allocation limit 1000 objects exceeded", ErrAllocLimitExceeded},
+		{&Interpreter{MaxAllocations: 1000}, `bytes("a" * 2000)`,
+			"allocation limit 1000 objects exceeded", ErrAllocLimitExceeded},
+		{&Interpreter{MaxAllocBytes: 100000}, `let s = ""; while (true) { s = s + "0123456789" }`,
+			"allocation limit 100000 bytes exceeded", ErrAllocLimitExceeded},
+		{&Interpreter{MaxAllocBytes: 100000}, `let s = ""; while (true) { s += "0123456789" }`,
+			"allocation limit 100000 bytes exceeded", ErrAllocLimitExceeded},
+		{&Interpreter{MaxAllocBytes: 100000}, `let h = {}; let i = 0; while (true) { h[i] = true; i = i + 1 }`,
+			"allocation limit 100000 bytes exceeded", ErrAllocLimitExceeded},
+		{&Interpreter{MaxAllocBytes: 100000}, `"abc" * 100000`,
+			"allocation limit 100000 bytes exceeded", ErrAllocLimitExceeded},
+		// 结果在分配之前计入预算，超出时不会真的分配
+		{&Interpreter{MaxAllocBytes: 100000}, `"abc" * 80000000`,
+			"allocation limit 100000 bytes exceeded", ErrAllocLimitExceeded},
+		{&Interpreter{MaxAllocBytes: 100000}, `"abc" * 9223372036854775807`,
+			"allocation limit 100000 bytes exceeded", ErrAllocLimitExceeded},
+		{&Interpreter{MaxAllocBytes: 100000}, `let a = [1]; while (true) { a = a + a }`,
+			"allocation limit 100000 bytes exceeded", ErrAllocLimitExceeded},
+		{&Interpreter{MaxAllocBytes: 100000}, `let a = [1]; while (true) { a += a }`,
+			"allocation limit 100000 bytes exceeded", ErrAllocLimitExceeded},
+		{&Interpreter{MaxAllocBytes: 80000}, `let a = bytes("a" * 2000); a[:]`,
+			"allocation limit 80000 bytes exceeded", ErrAllocLimitExceeded},
+		{&Interpreter{MaxAllocations: 1000}, `let s = "abc"; while (true) { s[::-1] }`,
+			"allocation limit 1000 objects exceeded", ErrAllocLimitExceeded},
+		{&Interpreter{MaxAllocations: 1000}, `let f = fn(...rest) { true }; let x = 1; while (true) { f(x, x) }`,
+			"allocation limit 1000 objects exceeded", ErrAllocLimitExceeded},
+		{&Interpreter{MaxAllocBytes: 100000}, `(2 ** 1048576) ** 1048576`,
+			"allocation limit 100000 bytes exceeded", ErrAllocLimitExceeded},
+		{&Interpreter{MaxAllocBytes: 100000}, `1 << 1000000`,
+			"allocation limit 100000 bytes exceeded", ErrAllocLimitExceeded},
+		{&Interpreter{MaxAllocBytes: 200000}, `let x = 2 ** 500000; x * x`,
+			"allocation limit 200000 bytes exceeded", ErrAllocLimitExceeded},
+		{&Interpreter{MaxAllocBytes: 200000}, `let x = 2 ** 500000; x *= x`,
+			"allocation limit 200000 bytes exceeded", ErrAllocLimitExceeded},
 	}
 	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := tt.in.Eval(program, object.NewEnvironment())
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+		if !errors.Is(errObj, tt.cause) {
+			t.Errorf("%s: wrong cause. expected=%v, got=%v", tt.input, tt.cause, errObj.Cause)
+		}
 	}
 }
 
-// ##############################################
-func TestFunctionObject(t *testing.T) {
-	input := "fn(x) { x + 2; };"
-	evaluated := testEval(input)
-	fn, ok := evaluated.(*object.Function)
-	if !ok {
-		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
-	}
-	if len(fn.Parameters) != 1 {
-		t.Fatalf("function has wrong parameters. Parameters=%+v",
-			fn.Parameters)
+func TestExecutionUsage(t *testing.T) {
+	input := `let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let words = [];
+for (w in ["a", "b", "c"]) { words = push(words, w * 3) }
+let h = {"fib": fib(10), "words": words};
+h["fib"]`
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	in := New()
+	testIntegerObject(t, in.Eval(program, object.NewEnvironment()), 55)
+	usage := in.Usage()
+	if usage.Steps == 0 || usage.Allocations == 0 || usage.AllocBytes == 0 {
+		t.Fatalf("usage not recorded. got=%+v", usage)
 	}
-	if fn.Parameters[0].String() != "x" {
-		t.Fatalf("parameter is not 'x'. got=%q", fn.Parameters[0])
+	// 同一个程序消耗的资源总是相同，每次求值重新计算
+	testIntegerObject(t, in.Eval(program, object.NewEnvironment()), 55)
+	if in.Usage() != usage {
+		t.Errorf("usage is not deterministic. first=%+v, second=%+v", usage, in.Usage())
 	}
-	expectedBody := "(x+2)"
-	if fn.Body.String() != expectedBody {
-		t.Fatalf("body is not %q. got=%q", expectedBody, fn.Body.String())
+
+	// 预算恰好够用时正常结束，少一点就会出错
+	exact := &Interpreter{MaxSteps: usage.Steps, MaxAllocations: usage.Allocations, MaxAllocBytes: usage.AllocBytes}
+	testIntegerObject(t, exact.Eval(program, object.NewEnvironment()), 55)
+	for _, in := range []*Interpreter{
+		{MaxSteps: usage.Steps - 1},
+		{MaxAllocations: usage.Allocations - 1},
+		{MaxAllocBytes: usage.AllocBytes - 1},
+	} {
+		if !isError(in.Eval(program, object.NewEnvironment())) {
+			t.Errorf("expected budget error with %+v", in)
+		}
 	}
 }
 
-// ##############################################
-func TestFunctionApplication(t *testing.T) {
+func TestFunctionDefaultAndRestParameters(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected int64
+		expected interface{}
 	}{
-		{"let identity = fn(x) { x; }; identity(5);", 5},
-		{"let identity = fn(x) { return x; }; identity(5);", 5},
-		{"let double = fn(x) { x * 2; }; double(5);", 10},
-		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
-		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
-		{"fn(x) { x; }(5)", 5},
+		{"let f = fn(x, y = 10) { x + y }; f(1)", 11},
+		{"let f = fn(x, y = 10) { x + y }; f(1, 2)", 3},
+		{"let f = fn(x, y = x * 2) { x + y }; f(3)", 9},
+		{"let n = 5; let f = fn(x = n) { x }; let n = 6; f()", 6},
+		{"let f = fn(...rest) { len(rest) }; f()", 0},
+		{"let f = fn(...rest) { len(rest) }; f(1, 2, 3)", 3},
+		{"let f = fn(first, ...rest) { first + last(rest) }; f(1, 2, 3)", 4},
+		{"let f = fn(a, b = 2, ...rest) { a + b + len(rest) }; f(1)", 3},
+		{"let f = fn(a, b = 2, ...rest) { a + b + len(rest) }; f(1, 5, 7, 7)", 8},
+		{"let f = fn(x, y = 10) { x + y }; f()", "wrong number of arguments. got=0, want=1..2"},
+		{"let f = fn(x, y = 10) { x + y }; f(1, 2, 3)", "wrong number of arguments. got=3, want=1..2"},
+		{"let f = fn(x, ...rest) { x }; f()", "wrong number of arguments. got=0, want>=1"},
+		{"let f = fn(x, y = z) { x }; f(1)", "identifier not found: z"},
 	}
 	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
 	}
 }
 
@@ -302,6 +1299,74 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestUnicodeStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("héllo")`, 5},
+		{`len("中文")`, 2},
+		{`len("😀!")`, 2},
+		{`len(bytes("中文"))`, 6},
+		{`"中文字"[1]`, "文"},
+		{`"a😀b"[1]`, "😀"},
+		{`"a😀b"[2]`, "b"},
+		{`"abc"[3]`, nil},
+		{`"abc"[-1]`, "c"},
+		{`"abc"[-4]`, nil},
+		{`"中文字"[-2]`, "文"},
+		{`let 名字 = "张三"; 名字 + "!"`, "张三!"},
+		{`let n = 0; for (c in "日本語") { n += 1; } n`, 3},
+		{`let s = ""; for (c in "中文") { s = c + s; } s`, "文中"},
+		{`bytes("é")`, "[195,169]"},
+		{`bytes("")`, "[]"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("%q: expected %q, got=%T(%s)", tt.input, expected, evaluated, evaluated.Inspect())
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Monkey"; "hello ${name}!"`, "hello Monkey!"},
+		{`let items = [1, 2, 3]; "you have ${len(items)} items"`, "you have 3 items"},
+		{`"${1 + 2}${true}${[1, "a"]}"`, "3true[1,a]"},
+		{`"${1.5 * 2} and ${2 ** 64}"`, "3.0 and 18446744073709551616"},
+		{`let f = fn(x) { "<${x}>" }; "${f(f("a"))}"`, "<<a>>"},
+		{`"${if (false) { 1 }}"`, "nil"},
+		{`"no interpolation: \${x} $x"`, "no interpolation: ${x} $x"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("%s: object is not String. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("%s: wrong value. expected=%q, got=%q", tt.input, tt.expected, str.Value)
+		}
+	}
+
+	evaluated := testEval(`"a ${missing} b"`)
+	if errObj, ok := evaluated.(*object.Error); !ok || errObj.Message != "identifier not found: missing" {
+		t.Errorf("expected error for undefined identifier. got=%T(%+v)", evaluated, evaluated)
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -310,6 +1375,7 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len(1.5)`, "argument to `len` not supported, got FLOAT"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 	}
 	for _, tt := range tests {
@@ -394,8 +1460,24 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
+		{
+			"let myArray = [1, 2, 3]; myArray[-1] = 5; myArray[2]",
+			5,
+		},
+		{
+			"let myArray = [1, 2, 3]; myArray[-2] += 5; myArray[1]",
+			7,
+		},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -408,6 +1490,72 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2, 3, 4, 5][1:3]`, "[2,3]"},
+		{`[1, 2, 3, 4, 5][:2]`, "[1,2]"},
+		{`[1, 2, 3, 4, 5][3:]`, "[4,5]"},
+		{`[1, 2, 3, 4, 5][:]`, "[1,2,3,4,5]"},
+		{`[1, 2, 3, 4, 5][-2:]`, "[4,5]"},
+		{`[1, 2, 3, 4, 5][:-2]`, "[1,2,3]"},
+		{`[1, 2, 3, 4, 5][::2]`, "[1,3,5]"},
+		{`[1, 2, 3, 4, 5][1::2]`, "[2,4]"},
+		{`[1, 2, 3, 4, 5][::-1]`, "[5,4,3,2,1]"},
+		{`[1, 2, 3, 4, 5][3:0:-1]`, "[4,3,2]"},
+		{`[1, 2, 3, 4, 5][-1:-4:-2]`, "[5,3]"},
+		{`[1, 2, 3, 4, 5][10:]`, "[]"},
+		{`[1, 2, 3, 4, 5][-10:2]`, "[1,2]"},
+		{`[1, 2, 3, 4, 5][3:1]`, "[]"},
+		{`[1, 2, 3][::9223372036854775807]`, "[1]"},
+		{`[1, 2, 3][::-9223372036854775807 - 1]`, "[3]"},
+		{`[][::-1]`, "[]"},
+		{`let a = [1, 2, 3]; let b = a[:]; b[0] = 9; a`, "[1,2,3]"},
+		{`let t = tuple(1, 2, 3); {t[1:]: "ok"}[tuple(2, 3)]`, "ok"},
+		{`let i = 1; [1, 2, 3, 4][i:i + 2]`, "[2,3]"},
+		{`"hello"[1:3]`, "el"},
+		{`"hello"[2:]`, "llo"},
+		{`"hello"[-3:]`, "llo"},
+		{`"hello"[::-1]`, "olleh"},
+		{`"中文字符"[1:3]`, "文字"},
+		{`"a😀b"[::-1]`, "b😀a"},
+		{`"hello"[10:]`, ""},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSliceErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2][::0]`, "slice step must not be zero"},
+		{`[1, 2]["a":]`, "slice index must be INTEGER, got STRING"},
+		{`"ab"[:1.5]`, "slice index must be INTEGER, got FLOAT"},
+		{`{"a": 1}[0:1]`, "slice operator not supported: HASH"},
+		{`5[0:1]`, "slice operator not supported: INTEGER"},
+		{`[1, 2][x:]`, "identifier not found: x"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
+
 func TestHashLiterals(t *testing.T) {
 	input := `let two = "two";
 				{
@@ -423,19 +1571,19 @@ func TestHashLiterals(t *testing.T) {
 	if !ok {
 		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
 	}
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
+	expected := map[object.Hashable]int64{
+		&object.String{Value: "one"}:   1,
+		&object.String{Value: "two"}:   2,
+		&object.String{Value: "three"}: 3,
+		&object.Integer{Value: 4}:      4,
+		TRUE:                           5,
+		FALSE:                          6,
 	}
 	if len(result.Pairs) != len(expected) {
 		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
 	}
 	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+		pair, ok := result.Get(expectedKey)
 		if !ok {
 			t.Errorf("no pair for given key in Pairs")
 		}
@@ -443,6 +1591,98 @@ func TestHashLiterals(t *testing.T) {
 	}
 }
 
+func TestHashInsertionOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"z": 1, "a": 2, "m": 3}`, `{z: 1, a: 2, m: 3}`},
+		{`{3: "c", 1: "a", 2: "b"}`, `{3: c, 1: a, 2: b}`},
+		{`{"a": 1, "b": 2, "a": 3}`, `{a: 3, b: 2}`},
+		{`let h = {"b": 1}; h["a"] = 2; h["c"] = 3; h["b"] = 4; h`, `{b: 4, a: 2, c: 3}`},
+		{`let s = ""; for (k in {"x": 1, "b": 2, "q": 3, "a": 4}) { s += k; } s`, "xbqa"},
+		// 键和值按源码顺序求值，副作用的顺序是确定的
+		{`let log = []; let f = fn(x) { log = push(log, x); x }; {f("k1"): f(1), f("k2"): f(2)}; log`, `[k1,1,k2,2]`},
+	}
+	for _, tt := range tests {
+		// 重复多次，避免碰巧与map的随机顺序一致
+		for i := 0; i < 20; i++ {
+			evaluated := testEval(tt.input)
+			if evaluated.Inspect() != tt.expected {
+				t.Fatalf("%s: expected %s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`[1, [2, 3]] == [1, [2, 3]]`, true},
+		{`[1, [2, 3]] == [1, [2, 4]]`, false},
+		{`[1, 2] == [1.0, 2]`, true},
+		{`{"a": 1, "b": 2} == {"b": 2, "a": 1}`, true},
+		{`{"a": 1, "b": 2} != {"b": 2, "a": 1}`, false},
+		{`{"a": [1, {"x": true}]} == {"a": [1, {"x": true}]}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{`{1: "one"} == {1.0: "one"}`, true},
+		{`{} == {}`, true},
+		{`[] == {}`, false},
+		{`tuple(1, 2) == [1, 2]`, true},
+		{`let a = [1, 2]; a[1] = a; let b = [1, 2]; b[1] = b; a == b`, true},
+		{`let a = [1, 2]; a[1] = a; let b = [3, 2]; b[1] = b; a == b`, false},
+		{`let h = {}; h["self"] = h; let g = {}; g["self"] = g; h == g`, true},
+	}
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestTupleHashKeys(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let memo = {}; memo[tuple(1, "a")] = 3; memo[tuple(1, "a")]`, 3},
+		{`{tuple(1, 2): 1, tuple(2, 1): 2}[tuple(2, 1)]`, 2},
+		{`{tuple(1, tuple(2, 3)): 5}[tuple(1, tuple(2, 3))]`, 5},
+		{`{tuple(1, 2): 5}[tuple(1.0, 2)]`, 5},
+		{`{tuple(): 7}[tuple()]`, 7},
+		{`{freeze([1, 2]): 4}[tuple(1, 2)]`, 4},
+		{`let h = {tuple(1, 2): 1}; h[tuple(1, 2)] = 2; h[tuple(1, 2)]`, 2},
+		{`{tuple(1, 2): 1}[tuple(1, 3)]`, nil},
+		{`{[1, 2]: 1}`, "unusable as hash key: ARRAY"},
+		{`{"a": 1}[[1]]`, "unusable as hash key: ARRAY"},
+		{`{tuple(1, [2]): 1}`, "unusable as hash key: ARRAY"},
+		{`{tuple(1, {}): 1}`, "unusable as hash key: ARRAY"},
+		{`let t = tuple(1, 2); t[0] = 5`, "cannot assign to element of immutable array"},
+		{`let a = [1, 2]; let t = freeze(a); a[0] = 5; t[0]`, 1},
+		{`freeze(1)`, "argument to `freeze` must be ARRAY, got INTEGER"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
 func TestHashIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -476,6 +1716,34 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{1: 5}[1.0]`,
+			5,
+		},
+		{
+			`{2.0: 5}[2]`,
+			5,
+		},
+		{
+			`{1.5: 5}[1.5]`,
+			5,
+		},
+		{
+			`{1.5: 5}[1]`,
+			nil,
+		},
+		{
+			`{1e19: 5}[10000000000000000000]`,
+			5,
+		},
+		{
+			`{10000000000000000000: 5}[1e19]`,
+			5,
+		},
+		{
+			`{-1e19: 5}[-10000000000000000000]`,
+			5,
+		},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -503,6 +1771,19 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if math.Abs(result.Value-expected) > 1e-9 {
+		t.Errorf("object has wrong value. got=%g, expected=%g", result.Value, expected)
+		return false
+	}
+	return true
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
 	if !ok {
@@ -1,52 +1,160 @@
 package lexer
 
-import "Monkey_1/token"
+import (
+	"Monkey_1/diagnostic"
+	"Monkey_1/token"
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Mode 控制词法分析器的行为，多个模式可以用 | 组合
+type Mode uint
+
+const (
+	ScanComments Mode = 1 << iota // 将注释作为COMMENT token返回，而不是跳过，供格式化或文档工具保留注释
+)
 
 type Lexer struct {
-	input        string
-	position     int  // 所输入字符串中的当前位置 （指向当前字符）
-	readPosition int  // 所输入字符串中的当前 读取 位置 （指向当前字符 的 后一个字符）
-	ch           byte // 当前正在查看的字符本身
+	mode         Mode
+	src          source
+	filename     string // 源码的文件名，仅用于记录token的位置
+	position     int    // 源码中的当前位置 （指向当前字符），以字节计
+	readPosition int    // 源码中的当前 读取 位置 （指向当前字符 的 后一个字符），以字节计
+	ch           rune   // 当前正在查看的字符本身，是UTF-8解码后的Unicode码点
+	line         int    // 当前字符所在的行，从1开始
+	column       int    // 当前字符所在的列，从1开始，以字符（码点）计
+	atEOF        bool   // 已经读到源码的结尾，此时ch为0
+
+	errors []diagnostic.Diagnostic // 词法分析中发现的错误，出错的位置会产生ILLEGAL token
+
+	// 每一层未结束的字符串插值 ${ 中尚未闭合的 { 的数量，插值中可以再嵌套字符串插值
+	// 遇到数量为0的层的 } 时，插值结束，继续读取字符串的剩余部分
+	templates []int
 }
 
 // New 根据input的source code创建一个语法分析器
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
-	// 初始化l中的position、readPosition，分别为0和1
+	return NewFile("", input)
+}
+
+// NewFile 与 New 相同，但产生的token的位置会带上文件名filename
+func NewFile(filename string, input string) *Lexer {
+	return NewFileReader(filename, strings.NewReader(input))
+}
+
+// NewReader 从r中边读边分析，不需要一次性将源码全部读入内存，产生的token与 New 完全相同
+// 读取出错时记录一条诊断信息，并将出错的位置当作源码的结尾
+func NewReader(r io.Reader) *Lexer {
+	return NewFileReader("", r)
+}
+
+// NewFileReader 与 NewReader 相同，但产生的token的位置会带上文件名filename
+func NewFileReader(filename string, r io.Reader) *Lexer {
+	l := &Lexer{src: source{reader: bufio.NewReader(r)}, filename: filename, line: 1}
+	// 初始化l中的position、readPosition，分别为0和第一个字符的宽度
 	l.readChar()
 	return l
 }
 
-// readChar 每次调用时读取Lexer.input的当前字符 并将position & readPosition后移
+// SetMode 设置词法分析器的模式，默认跳过所有注释
+func (l *Lexer) SetMode(mode Mode) {
+	l.mode = mode
+}
+
+// readChar 每次调用时读取源码的下一个字符 并将position & readPosition后移
 func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
-		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+	if l.atEOF {
+		return // 已经停在EOF上，不再移动，保证EOF的位置不变
+	}
+	// 跨过换行符后，行号加一，列号重新计数
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	// 不合法的UTF-8字节解码为utf8.RuneError，宽度为1
+	ch, width := l.src.decode(l.readPosition)
+	l.ch = ch
+	l.readPosition += width
+	l.column += 1
+	if width == 0 {
+		l.atEOF = true
+		if err := l.src.err; err != nil {
+			pos := l.curPosition()
+			l.errorRange(diagnostic.ReadError, pos, pos, fmt.Sprintf("read error: %v", err))
+		}
+	}
+}
+
+// curPosition 返回当前字符l.ch的位置
+func (l *Lexer) curPosition() token.Position {
+	return token.Position{Filename: l.filename, Offset: l.position, Line: l.line, Column: l.column}
 }
 
 // peekChar 与 readChar 类似，但只窥探后面一个字符串，不移动position & readPosition
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
-		return 0
-	} else {
-		return l.input[l.readPosition]
+func (l *Lexer) peekChar() rune {
+	return l.peekCharN(1)
+}
+
+// peekCharN 窥探当前字符之后的第n个字符，peekCharN(1) 等价于 peekChar()
+func (l *Lexer) peekCharN(n int) rune {
+	offset := l.readPosition
+	for ; n > 1; n-- {
+		_, width := l.src.decode(offset)
+		if width == 0 {
+			return 0
+		}
+		offset += width
 	}
+	r, _ := l.src.decode(offset)
+	return r
+}
+
+// text 返回源码中[start, end)之间的文本，start不能早于当前token的开头
+func (l *Lexer) text(start, end int) string {
+	return l.src.text(start, end)
 }
 
 // newToken 根据tokenType和ch新建token，仅用于token的长度为1个字符的情况
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
+// makeTwoCharToken 下一个字符是second时，与当前字符组成双字符token（如 == +=），否则是单字符token
+func (l *Lexer) makeTwoCharToken(second rune, twoCharType, oneCharType token.TokenType) token.Token {
+	if l.peekChar() == second {
+		ch := l.ch
+		l.readChar()
+		return token.Token{Type: twoCharType, Literal: string(ch) + string(l.ch)}
+	}
+	return newToken(oneCharType, l.ch)
+}
+
 // NextToken 每次调用时返回当前的token
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
+	// 之前的token已经不再需要，读取更多源码时可以丢弃它们占用的缓冲区
+	l.src.release(l.position)
 	// 跳过空字符串，包括\n，直到l.ch为非空字符串
 	l.skipWhitespace()
+	pos := l.curPosition()
+
+	// 注释，默认模式下跳过注释及其后的空白
+	for l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*') {
+		tok = l.readComment(pos)
+		tok.Pos, tok.End = pos, l.curPosition()
+		if tok.Type == token.ILLEGAL || l.mode&ScanComments != 0 {
+			return tok
+		}
+		l.skipWhitespace()
+		pos = l.curPosition()
+	}
+	tok = token.Token{}
 
 	// 根据当前l.ch，返回对应的token
 	switch l.ch {
@@ -57,51 +165,76 @@ func (l *Lexer) NextToken() token.Token {
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
 	case '{':
+		if n := len(l.templates); n > 0 {
+			l.templates[n-1]++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
 		tok = newToken(token.RBRACKET, l.ch)
 	case '}':
+		if n := len(l.templates); n > 0 && l.templates[n-1] == 0 {
+			l.templates = l.templates[:n-1]
+			tok = l.readString(pos, true)
+			break
+		} else if n > 0 {
+			l.templates[n-1]--
+		}
 		tok = newToken(token.RBRACE, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.makeTwoCharToken('=', token.PLUS_ASSIGN, token.PLUS)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.peekCharN(2) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+			l.error(diagnostic.IllegalCharacter, pos, fmt.Sprintf("illegal character %q", l.ch))
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.makeTwoCharToken('=', token.MINUS_ASSIGN, token.MINUS)
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			tok = l.makeTwoCharToken('*', token.POWER, token.ASTERISK)
+		} else {
+			tok = l.makeTwoCharToken('=', token.ASTERISK_ASSIGN, token.ASTERISK)
+		}
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
+	case '^':
+		tok = newToken(token.CARET, l.ch)
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		tok = l.makeTwoCharToken('=', token.SLASH_ASSIGN, token.SLASH)
 	case '<':
-		tok = newToken(token.LT, l.ch)
-	case '>':
-		tok = newToken(token.GT, l.ch)
-		// case '=' 和 case '!'相似性略高，若有更多的双字符token，可以考虑合并成一个函数 makeTwoCharToken
-	case '=':
-		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			literal := string(ch) + string(l.ch) // “==”
-			tok = token.Token{Type: token.EQ, Literal: literal}
+		if l.peekChar() == '<' {
+			tok = l.makeTwoCharToken('<', token.SHL, token.LT)
 		} else {
-			tok = newToken(token.ASSIGN, l.ch)
+			tok = l.makeTwoCharToken('=', token.LT_EQ, token.LT)
 		}
-	case '!':
-		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.NOT_EQ, Literal: literal}
+	case '>':
+		if l.peekChar() == '>' {
+			tok = l.makeTwoCharToken('>', token.SHR, token.GT)
 		} else {
-			tok = newToken(token.BANG, l.ch)
+			tok = l.makeTwoCharToken('=', token.GT_EQ, token.GT)
 		}
+	case '=':
+		tok = l.makeTwoCharToken('=', token.EQ, token.ASSIGN)
+	case '!':
+		tok = l.makeTwoCharToken('=', token.NOT_EQ, token.BANG)
+	case '&':
+		tok = l.makeTwoCharToken('&', token.AND, token.AMPERSAND)
+	case '|':
+		tok = l.makeTwoCharToken('|', token.OR, token.PIPE)
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok = l.readString(pos, false)
+	case '`':
+		tok = l.readRawString(pos)
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -111,48 +244,278 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Literal = l.readIdentifier()
 			// 是字符串，并进一步区分是用户自定标识符还是关键词
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos, tok.End = pos, l.curPosition()
 			return tok
 		} else if isDigital(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok = l.readNumber(pos)
+			tok.Pos, tok.End = pos, l.curPosition()
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
+			if l.ch == utf8.RuneError {
+				l.error(diagnostic.IllegalCharacter, pos, "invalid UTF-8 encoding")
+			} else {
+				l.error(diagnostic.IllegalCharacter, pos, fmt.Sprintf("illegal character %q", l.ch))
+			}
 		}
 	}
 	// 检查后，字符指针移动
 	l.readChar()
+	tok.Pos, tok.End = pos, l.curPosition()
 	return tok
 }
 
-// readIdentifier 读取标识符直到遇见非字母字符
+// readIdentifier 读取标识符直到遇见非字母字符，标识符可以含有Unicode字母和数字
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) || isDigital(l.ch) {
+	for isLetter(l.ch) || unicode.IsDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position] // 即position:l.position-1之间的字符串就是标识符
+	return l.text(position, l.position) // 即position:l.position-1之间的字符串就是标识符
 }
 
-// readIdentifier 读取数字直到遇见非数字字符
-func (l *Lexer) readNumber() string {
+// readNumber 读取数字直到遇见非数字字符，带小数部分或指数部分的是FLOAT，否则是INT
+// 整数可以带 0x 0o 0b 前缀，数字之间可以用 _ 分隔，如 0xFF、0o755、0b1010、1_000_000
+// 数字之后紧跟字母或数字（如 12abc、0b102）时，整体作为一个不合法的数字报告，而不是拆成两个token
+func (l *Lexer) readNumber(pos token.Position) token.Token {
 	position := l.position
-	for isDigital(l.ch) {
+	var tokenType token.TokenType = token.INT
+	base := 10
+	if l.ch == '0' {
+		switch l.peekChar() {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 10 {
+			l.readChar()
+			l.readChar()
+		}
+	}
+	digits := l.position
+	l.readDigits(base)
+	if base != 10 {
+		hasDigits := strings.Trim(l.text(digits, l.position), "_") != ""
+		return l.checkNumber(pos, position, base, hasDigits, tokenType)
+	}
+	// 小数部分，. 之后必须紧跟数字，否则 . 不属于这个数字
+	if l.ch == '.' && isDigital(l.peekChar()) {
+		tokenType = token.FLOAT
 		l.readChar()
+		l.readDigits(10)
+	}
+	// 指数部分，e或E之后可以有正负号，之后必须紧跟数字，否则e不属于这个数字
+	if l.ch == 'e' || l.ch == 'E' {
+		if isDigital(l.peekChar()) ||
+			(l.peekChar() == '+' || l.peekChar() == '-') && isDigital(l.peekCharN(2)) {
+			tokenType = token.FLOAT
+			l.readChar() // e
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits(10)
+		}
 	}
-	return l.input[position:l.position] // 即position:l.position-1之间的字符串就是标识符
+	return l.checkNumber(pos, position, base, true, tokenType)
 }
 
-// readString
-func (l *Lexer) readString() string {
-	position := l.position + 1
+// checkNumber 检查从position开始、刚读取完的数字，合法时返回对应的token，否则记录错误并返回ILLEGAL token
+func (l *Lexer) checkNumber(pos token.Position, position, base int, hasDigits bool, tokenType token.TokenType) token.Token {
+	if isLetter(l.ch) || unicode.IsDigit(l.ch) {
+		for isLetter(l.ch) || unicode.IsDigit(l.ch) {
+			l.readChar()
+		}
+		return l.illegalNumber(pos, position, "invalid number literal %q")
+	}
+	literal := l.text(position, l.position) // 即position:l.position-1之间的字符串就是数字
+	if !hasDigits {
+		return l.illegalNumber(pos, position, "invalid number literal %q: no digits after base prefix")
+	}
+	for i := 0; i < len(literal); i++ {
+		if literal[i] != '_' {
+			continue
+		}
+		// _ 只能出现在两个数字之间，或者前缀与数字之间，如 1_000、0x_FF
+		prevOK := i > 0 && isDigitOfBase(rune(literal[i-1]), base) || i == 2 && base != 10
+		nextOK := i+1 < len(literal) && isDigitOfBase(rune(literal[i+1]), base)
+		if !prevOK || !nextOK {
+			return l.illegalNumber(pos, position, "invalid number literal %q: '_' must separate successive digits")
+		}
+	}
+	return token.Token{Type: tokenType, Literal: literal}
+}
+
+// illegalNumber 记录从position到当前字符之前的不合法数字，format中的%q是数字的原文
+func (l *Lexer) illegalNumber(pos token.Position, position int, format string) token.Token {
+	literal := l.text(position, l.position)
+	l.errorRange(diagnostic.InvalidNumber, pos, l.curPosition(), fmt.Sprintf(format, literal))
+	return token.Token{Type: token.ILLEGAL, Literal: literal}
+}
+
+// readDigits 读取连续的base进制的数字，以及数字之间的分隔符 _
+func (l *Lexer) readDigits(base int) {
+	for isDigitOfBase(l.ch, base) || l.ch == '_' {
+		l.readChar()
+	}
+}
+
+// readString 读取双引号字符串，token的Literal是转义之后的值
+// 遇到 ${ 时停止，返回插值之前的部分，resumed表示从插值结束的 } 处继续读取字符串
+// 字符串没有闭合或含有不合法的转义序列时，记录错误并返回ILLEGAL token，Literal为源码中的原文
+func (l *Lexer) readString(pos token.Position, resumed bool) token.Token {
+	position := l.position
+	var out strings.Builder
+	valid := true
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		switch l.ch {
+		case '"':
+			if !valid {
+				return token.Token{Type: token.ILLEGAL, Literal: l.text(position, l.position+1)}
+			}
+			if resumed {
+				return token.Token{Type: token.TEMPLATE_TAIL, Literal: out.String()}
+			}
+			return token.Token{Type: token.STRING, Literal: out.String()}
+		case '$':
+			if l.peekChar() != '{' {
+				out.WriteRune(l.ch)
+				continue
+			}
+			l.readChar()
+			l.templates = append(l.templates, 0)
+			if !valid {
+				return token.Token{Type: token.ILLEGAL, Literal: l.text(position, l.position+1)}
+			}
+			if resumed {
+				return token.Token{Type: token.TEMPLATE_MIDDLE, Literal: out.String()}
+			}
+			return token.Token{Type: token.TEMPLATE_HEAD, Literal: out.String()}
+		case 0:
+			l.error(diagnostic.UnterminatedString, pos, "unterminated string literal")
+			return token.Token{Type: token.ILLEGAL, Literal: l.text(position, l.position)}
+		case '\\':
+			if !l.readEscape(&out) {
+				valid = false // 继续读到字符串结尾，一个字符串中的多个错误都能被报告
+			}
+		default:
+			// 写入原始字节而不是l.ch，不合法的UTF-8字节也能原样保留
+			out.WriteString(l.text(l.position, l.readPosition))
+		}
+	}
+}
+
+// readEscape 读取 \ 之后的转义序列，将其代表的字符写入out，结束时l.ch是转义序列的最后一个字符
+// 支持 \n \t \r \0 \\ \" \$ \xNN（一个字节）和 \u{N...}（1到6位十六进制的Unicode码点）
+func (l *Lexer) readEscape(out *strings.Builder) bool {
+	pos := l.curPosition()
+	l.readChar()
+	switch l.ch {
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case 'r':
+		out.WriteByte('\r')
+	case '0':
+		out.WriteByte(0)
+	case '\\', '"', '$':
+		out.WriteRune(l.ch)
+	case 'x':
+		if !isHexDigit(l.peekChar()) || !isHexDigit(l.peekCharN(2)) {
+			l.error(diagnostic.InvalidEscape, pos, `invalid escape sequence: \x must be followed by two hex digits`)
+			return false
+		}
+		l.readChar()
+		l.readChar()
+		value, _ := strconv.ParseUint(l.text(l.position-1, l.position+1), 16, 8)
+		out.WriteByte(byte(value))
+	case 'u':
+		return l.readUnicodeEscape(pos, out)
+	case 0:
+		return true // 字符串没有闭合，由readString报告
+	default:
+		l.error(diagnostic.InvalidEscape, pos, fmt.Sprintf("invalid escape sequence: \\%c", l.ch))
+		return false
+	}
+	return true
+}
+
+// readUnicodeEscape 读取 \u{...}，此时l.ch是u
+func (l *Lexer) readUnicodeEscape(pos token.Position, out *strings.Builder) bool {
+	if l.peekChar() != '{' {
+		l.error(diagnostic.InvalidEscape, pos, `invalid escape sequence: \u must be followed by {hex digits}`)
+		return false
+	}
+	l.readChar()
+	start := l.readPosition
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+	}
+	digits := l.text(start, l.readPosition)
+	if l.peekChar() != '}' || len(digits) == 0 || len(digits) > 6 {
+		l.error(diagnostic.InvalidEscape, pos, `invalid escape sequence: \u{...} must contain 1 to 6 hex digits`)
+		return false
+	}
+	l.readChar()
+	value, _ := strconv.ParseUint(digits, 16, 32)
+	r := rune(value)
+	if !utf8.ValidRune(r) {
+		// 超出Unicode范围，或是UTF-16代理项
+		l.error(diagnostic.InvalidEscape, pos, fmt.Sprintf("invalid escape sequence: U+%X is not a valid code point", value))
+		return false
+	}
+	out.WriteRune(r)
+	return true
+}
+
+// readRawString 读取反引号括起的原始字符串，其中没有转义，可以跨越多行
+func (l *Lexer) readRawString(pos token.Position) token.Token {
+	position := l.position
+	for {
+		l.readChar()
+		switch l.ch {
+		case '`':
+			return token.Token{Type: token.STRING, Literal: l.text(position+1, l.position)}
+		case 0:
+			l.error(diagnostic.UnterminatedString, pos, "unterminated raw string literal")
+			return token.Token{Type: token.ILLEGAL, Literal: l.text(position, l.position)}
 		}
 	}
-	return l.input[position:l.position]
+}
+
+// readComment 读取 // 行注释（不含行尾的换行符）或 /* */ 块注释，块注释可以嵌套
+// 块注释没有闭合时记录错误并返回ILLEGAL token
+func (l *Lexer) readComment(pos token.Position) token.Token {
+	position := l.position
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+		return token.Token{Type: token.COMMENT, Literal: l.text(position, l.position)}
+	}
+
+	l.readChar() // /
+	l.readChar() // *
+	depth := 1
+	for depth > 0 {
+		switch {
+		case l.ch == 0:
+			l.error(diagnostic.UnterminatedComment, pos, "unterminated block comment")
+			return token.Token{Type: token.ILLEGAL, Literal: l.text(position, l.position)}
+		case l.ch == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+		}
+		l.readChar()
+	}
+	return token.Token{Type: token.COMMENT, Literal: l.text(position, l.position)}
 }
 
 // skipWhitespace 跳过空白的字符，包括换行符
@@ -162,10 +525,56 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-func isDigital(ch byte) bool {
+func isDigital(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func isDigitOfBase(ch rune, base int) bool {
+	switch base {
+	case 2:
+		return ch == '0' || ch == '1'
+	case 8:
+		return '0' <= ch && ch <= '7'
+	case 16:
+		return isHexDigit(ch)
+	default:
+		return isDigital(ch)
+	}
+}
+
+func isHexDigit(ch rune) bool {
+	return isDigital(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+// isLetter 除了ASCII字母和下划线，也接受其他语言的字母，如 let 名字 = "张三"
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
+}
+
+// Errors 返回词法分析至今发现的错误
+func (l *Lexer) Errors() []diagnostic.Diagnostic {
+	return l.errors
+}
+
+// error 记录一条从pos到当前字符（含）的错误
+func (l *Lexer) error(code diagnostic.Code, pos token.Position, msg string) {
+	end := l.curPosition()
+	if l.ch != 0 {
+		end.Offset = l.readPosition
+		end.Column += 1
+	}
+	l.errorRange(code, pos, end, msg)
+}
+
+// errorRange 记录一条从pos到end（不含）的错误
+func (l *Lexer) errorRange(code diagnostic.Code, pos, end token.Position, msg string) {
+	l.errors = append(l.errors, diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Code:     code,
+		Message:  msg,
+		Pos:      pos,
+		End:      end,
+		Actual:   token.ILLEGAL,
+	})
 }
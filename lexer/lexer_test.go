@@ -1,8 +1,14 @@
 package lexer
 
 import (
+	"Monkey_1/diagnostic"
 	"Monkey_1/token"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestNextToken(t *testing.T) {
@@ -15,7 +21,7 @@ func TestNextToken(t *testing.T) {
 		};
 		
 		let result = add(five, ten);
-		!-/*5;
+		!-/ *5;
 		5 < 10 > 5;
 		
 		if (5 < 10) {
@@ -137,3 +143,550 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestAssignmentOperators(t *testing.T) {
+	input := `x = 1; x += 2; x -= 3; x *= 4; x /= 5; x == 6;`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"}, {token.ASSIGN, "="}, {token.INT, "1"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.PLUS_ASSIGN, "+="}, {token.INT, "2"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.MINUS_ASSIGN, "-="}, {token.INT, "3"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.ASTERISK_ASSIGN, "*="}, {token.INT, "4"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.SLASH_ASSIGN, "/="}, {token.INT, "5"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.EQ, "=="}, {token.INT, "6"}, {token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - token wrong. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestLoopKeywords(t *testing.T) {
+	input := `while for in break continue inner`
+	expected := []token.TokenType{token.WHILE, token.FOR, token.IN, token.BREAK, token.CONTINUE, token.IDENT, token.EOF}
+
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	l := New(`a && b || c & d | e`)
+	expected := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"}, {token.AND, "&&"}, {token.IDENT, "b"}, {token.OR, "||"},
+		{token.IDENT, "c"}, {token.AMPERSAND, "&"}, {token.IDENT, "d"}, {token.PIPE, "|"},
+		{token.IDENT, "e"}, {token.EOF, ""},
+	}
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - token wrong. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestArithmeticOperators(t *testing.T) {
+	l := New(`a <= b >= c < d > e % f ^ g << h >> i ** j * k *= l`)
+	expected := []token.TokenType{
+		token.IDENT, token.LT_EQ, token.IDENT, token.GT_EQ, token.IDENT, token.LT, token.IDENT,
+		token.GT, token.IDENT, token.PERCENT, token.IDENT, token.CARET, token.IDENT, token.SHL,
+		token.IDENT, token.SHR, token.IDENT, token.POWER, token.IDENT, token.ASTERISK, token.IDENT,
+		token.ASTERISK_ASSIGN, token.IDENT, token.EOF,
+	}
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 1; // trailing comment
+/* block /* nested */ still comment */ x / /**/ 2
+/*
+multi-line
+*/`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.COMMENT, "// leading comment"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// trailing comment"},
+		{token.COMMENT, "/* block /* nested */ still comment */"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.COMMENT, "/**/"},
+		{token.INT, "2"},
+		{token.COMMENT, "/*\nmulti-line\n*/"},
+		{token.EOF, ""},
+	}
+
+	// 默认模式下跳过注释
+	l := New(input)
+	for i, tt := range tests {
+		if tt.expectedType == token.COMMENT {
+			continue
+		}
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - token wrong. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	// ScanComments 模式下注释作为token返回
+	l = New(input)
+	l.SetMode(ScanComments)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - token wrong. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+	if len(l.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", l.Errors())
+	}
+}
+
+func TestUnterminatedComment(t *testing.T) {
+	l := New("let x = 1;\n/* outer /* inner */ never closed")
+	var tok token.Token
+	for tok = l.NextToken(); tok.Type != token.ILLEGAL && tok.Type != token.EOF; tok = l.NextToken() {
+	}
+	if tok.Type != token.ILLEGAL {
+		t.Fatalf("expected ILLEGAL token. got=%q", tok.Type)
+	}
+	if tok.Pos.String() != "2:1" {
+		t.Errorf("tok.Pos wrong. expected=2:1, got=%s", tok.Pos)
+	}
+	if tok := l.NextToken(); tok.Type != token.EOF {
+		t.Errorf("expected EOF after ILLEGAL. got=%q", tok.Type)
+	}
+	errors := l.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error. got=%d", len(errors))
+	}
+	if errors[0].Code != diagnostic.UnterminatedComment || errors[0].Pos.String() != "2:1" {
+		t.Errorf("wrong error. got=%s", errors[0])
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\nb"`, "a\nb"},
+		{`"tab\there"`, "tab\there"},
+		{`"\r\0"`, "\r\x00"},
+		{`"quote: \"hi\""`, `quote: "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"\x41\x7a"`, "Az"},
+		{`"\xe4\xb8\xad"`, "中"},
+		{`"\u{e9}"`, "é"},
+		{`"\u{4E2D}\u{1F600}"`, "中😀"},
+		{`"é"`, "é"},
+		{"`raw \\n string`", `raw \n string`},
+		{"`multi\nline \"quoted\"`", "multi\nline \"quoted\""},
+		{"``", ""},
+	}
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.STRING {
+			t.Errorf("%s: tokentype wrong. expected=%q, got=%q (%v)", tt.input, token.STRING, tok.Type, l.Errors())
+			continue
+		}
+		if tok.Literal != tt.expected {
+			t.Errorf("%s: literal wrong. expected=%q, got=%q", tt.input, tt.expected, tok.Literal)
+		}
+		if tok := l.NextToken(); tok.Type != token.EOF {
+			t.Errorf("%s: expected EOF. got=%q", tt.input, tok.Type)
+		}
+	}
+}
+
+func TestInvalidStrings(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedCode diagnostic.Code
+		expectedPos  string
+		expectedMsg  string
+	}{
+		{`"abc`, diagnostic.UnterminatedString, "1:1", "unterminated string literal"},
+		{`x = "abc\"`, diagnostic.UnterminatedString, "1:5", "unterminated string literal"},
+		{"`abc\n", diagnostic.UnterminatedString, "1:1", "unterminated raw string literal"},
+		{`"a\qb"`, diagnostic.InvalidEscape, "1:3", `invalid escape sequence: \q`},
+		{`"\x4"`, diagnostic.InvalidEscape, "1:2", `invalid escape sequence: \x must be followed by two hex digits`},
+		{`"\u41"`, diagnostic.InvalidEscape, "1:2", `invalid escape sequence: \u must be followed by {hex digits}`},
+		{`"\u{}"`, diagnostic.InvalidEscape, "1:2", `invalid escape sequence: \u{...} must contain 1 to 6 hex digits`},
+		{`"\u{1234567}"`, diagnostic.InvalidEscape, "1:2", `invalid escape sequence: \u{...} must contain 1 to 6 hex digits`},
+		{`"\u{D800}"`, diagnostic.InvalidEscape, "1:2", `invalid escape sequence: U+D800 is not a valid code point`},
+		{`"\u{110000}"`, diagnostic.InvalidEscape, "1:2", `invalid escape sequence: U+110000 is not a valid code point`},
+	}
+	for _, tt := range tests {
+		l := New(tt.input)
+		var tok token.Token
+		for tok = l.NextToken(); tok.Type != token.ILLEGAL && tok.Type != token.EOF; tok = l.NextToken() {
+		}
+		if tok.Type != token.ILLEGAL {
+			t.Errorf("%s: expected ILLEGAL token", tt.input)
+			continue
+		}
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%s: expected 1 error. got=%d (%v)", tt.input, len(errors), errors)
+			continue
+		}
+		if errors[0].Code != tt.expectedCode || errors[0].Pos.String() != tt.expectedPos || errors[0].Message != tt.expectedMsg {
+			t.Errorf("%s: wrong error. expected=%s: [%s] %s, got=%s", tt.input, tt.expectedPos, tt.expectedCode, tt.expectedMsg, errors[0])
+		}
+	}
+
+	// 一个字符串中的多个错误都会被报告，字符串之后的token不受影响
+	l := New(`"\q\w" 1`)
+	if tok := l.NextToken(); tok.Type != token.ILLEGAL || tok.Literal != `"\q\w"` {
+		t.Errorf("expected ILLEGAL token with source literal. got=%q %q", tok.Type, tok.Literal)
+	}
+	if tok := l.NextToken(); tok.Type != token.INT {
+		t.Errorf("expected INT after invalid string. got=%q", tok.Type)
+	}
+	if len(l.Errors()) != 2 {
+		t.Errorf("expected 2 errors. got=%d", len(l.Errors()))
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	input := "let 名字 = \"张三😀\";\nlet café_2 = 名字;"
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedPos     string
+	}{
+		{token.LET, "let", "1:1"},
+		{token.IDENT, "名字", "1:5"},
+		{token.ASSIGN, "=", "1:8"},
+		{token.STRING, "张三😀", "1:10"},
+		{token.SEMICOLON, ";", "1:15"},
+		{token.LET, "let", "2:1"},
+		{token.IDENT, "café_2", "2:5"},
+		{token.ASSIGN, "=", "2:12"},
+		{token.IDENT, "名字", "2:14"},
+		{token.SEMICOLON, ";", "2:16"},
+		{token.EOF, "", "2:17"},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - token wrong. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Pos.String() != tt.expectedPos {
+			t.Errorf("test[%d] - position wrong. expected=%s, got=%s", i, tt.expectedPos, tok.Pos)
+		}
+	}
+	// Offset以字节计
+	l = New("名字 x")
+	l.NextToken()
+	if tok := l.NextToken(); tok.Pos.Offset != 7 || tok.Pos.Column != 4 {
+		t.Errorf("offset or column wrong. got offset=%d column=%d", tok.Pos.Offset, tok.Pos.Column)
+	}
+}
+
+func TestIllegalUnicode(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedMsg string
+	}{
+		{"x → y", `illegal character '→'`},
+		{"x \xff y", "invalid UTF-8 encoding"},
+	}
+	for _, tt := range tests {
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+		errors := l.Errors()
+		if len(errors) != 1 || errors[0].Message != tt.expectedMsg {
+			t.Errorf("%q: expected error %q. got=%v", tt.input, tt.expectedMsg, errors)
+			continue
+		}
+		if errors[0].Pos.Column != 3 || errors[0].End.Column != 4 {
+			t.Errorf("%q: error range wrong. got=%s-%s", tt.input, errors[0].Pos, errors[0].End)
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	input := `"hello ${name}, ${ {"a": 1}["a"] + 1 } and ${"x${y}z"}!" "$5 \${no}"`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.TEMPLATE_HEAD, "hello "},
+		{token.IDENT, "name"},
+		{token.TEMPLATE_MIDDLE, ", "},
+		{token.LBRACE, "{"},
+		{token.STRING, "a"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "a"},
+		{token.RBRACKET, "]"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.TEMPLATE_MIDDLE, " and "},
+		{token.TEMPLATE_HEAD, "x"},
+		{token.IDENT, "y"},
+		{token.TEMPLATE_TAIL, "z"},
+		{token.TEMPLATE_TAIL, "!"},
+		{token.STRING, "$5 ${no}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - token wrong. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := `5 3.14 0.5 1e10 2.5E-3 6e+2 7.foo 8e [1][0] 9.`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "0.5"},
+		{token.FLOAT, "1e10"},
+		{token.FLOAT, "2.5E-3"},
+		{token.FLOAT, "6e+2"},
+		{token.INT, "7"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "foo"},
+		{token.ILLEGAL, "8e"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.RBRACKET, "]"},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.INT, "9"},
+		{token.ILLEGAL, "."},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestIntegerBases(t *testing.T) {
+	input := `0xFF 0Xab_cd 0o755 0O7 0b1010 0B1_0 1_000_000 0x_1 1_0.2_5 0`
+	expected := []string{"0xFF", "0Xab_cd", "0o755", "0O7", "0b1010", "0B1_0", "1_000_000", "0x_1", "1_0.2_5", "0"}
+
+	l := New(input)
+	for i, lit := range expected {
+		tok := l.NextToken()
+		if tok.Literal != lit || tok.Type == token.ILLEGAL {
+			t.Fatalf("test[%d] - token wrong. expected=%q, got=%q %q", i, lit, tok.Type, tok.Literal)
+		}
+	}
+	if len(l.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", l.Errors())
+	}
+}
+
+func TestMalformedNumbers(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedLit string
+		expectedMsg string
+	}{
+		{"12abc", "12abc", `invalid number literal "12abc"`},
+		{"0x", "0x", `invalid number literal "0x": no digits after base prefix`},
+		{"0b", "0b", `invalid number literal "0b": no digits after base prefix`},
+		{"0x_", "0x_", `invalid number literal "0x_": no digits after base prefix`},
+		{"0b102", "0b102", `invalid number literal "0b102"`},
+		{"0o8", "0o8", `invalid number literal "0o8"`},
+		{"0xFG", "0xFG", `invalid number literal "0xFG"`},
+		{"1.5x", "1.5x", `invalid number literal "1.5x"`},
+		{"1e", "1e", `invalid number literal "1e"`},
+		{"1__0", "1__0", `invalid number literal "1__0": '_' must separate successive digits`},
+		{"10_", "10_", `invalid number literal "10_": '_' must separate successive digits`},
+		{"1_.5", "1_.5", `invalid number literal "1_.5": '_' must separate successive digits`},
+		{"1e_5", "1e_5", `invalid number literal "1e_5"`},
+		{"3中", "3中", `invalid number literal "3中"`},
+	}
+	for _, tt := range tests {
+		l := New(tt.input + ";")
+		tok := l.NextToken()
+		if tok.Type != token.ILLEGAL || tok.Literal != tt.expectedLit {
+			t.Errorf("%s: expected ILLEGAL %q. got=%q %q", tt.input, tt.expectedLit, tok.Type, tok.Literal)
+			continue
+		}
+		if next := l.NextToken(); next.Type != token.SEMICOLON {
+			t.Errorf("%s: malformed number was split. next token=%q %q", tt.input, next.Type, next.Literal)
+		}
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%s: expected 1 error. got=%d (%v)", tt.input, len(errors), errors)
+			continue
+		}
+		if errors[0].Code != diagnostic.InvalidNumber || errors[0].Message != tt.expectedMsg {
+			t.Errorf("%s: wrong error. expected=%q, got=%s", tt.input, tt.expectedMsg, errors[0])
+		}
+		if errors[0].End.Offset != len(tt.input) {
+			t.Errorf("%s: error end wrong. got=%d", tt.input, errors[0].End.Offset)
+		}
+	}
+}
+
+// collectTokens 读取所有token，直到EOF
+func collectTokens(l *Lexer) []token.Token {
+	var tokens []token.Token
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
+func TestReaderMatchesString(t *testing.T) {
+	inputs := []string{
+		"let add = fn(x, y = 10, ...rest) { x + y };\nadd(1, 2.5e3);",
+		"/* 注释 /* 嵌套 */ */ let 名字 = \"张三 ${1 + {\"a\": 2}[\"a\"]} 😀\"; // 行尾",
+		"`raw\nstring` \"\\u{1F600}\\x41\" 0xFF_FF 0b12 12abc \"unterminated",
+		"x \xff y → z",
+		"",
+	}
+	// 超过缓冲区大小的输入，token会跨越两次读取的边界
+	var long strings.Builder
+	for i := 0; long.Len() < 3*bufferSize; i++ {
+		long.WriteString("let 变量_")
+		long.WriteString(strings.Repeat("x", i%50))
+		long.WriteString(" = \"字符串 ${i}\" + 12345.678; /* 注释 */\n")
+	}
+	inputs = append(inputs, long.String())
+
+	for _, input := range inputs {
+		expected := collectTokens(NewFile("a.mk", input))
+		expectedErrors := NewFile("a.mk", input)
+		collectTokens(expectedErrors)
+
+		readers := map[string]io.Reader{
+			"reader":  strings.NewReader(input),
+			"onebyte": iotest.OneByteReader(strings.NewReader(input)),
+			"half":    iotest.HalfReader(strings.NewReader(input)),
+		}
+		for name, r := range readers {
+			l := NewFileReader("a.mk", r)
+			actual := collectTokens(l)
+			if !reflect.DeepEqual(expected, actual) {
+				t.Errorf("%s: tokens differ from string lexer for input %.40q", name, input)
+			}
+			if !reflect.DeepEqual(expectedErrors.Errors(), l.Errors()) {
+				t.Errorf("%s: errors differ. expected=%v, got=%v", name, expectedErrors.Errors(), l.Errors())
+			}
+		}
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("let x = 1;\nx +"), iotest.ErrReader(errors.New("disk on fire")))
+	l := NewReader(r)
+	tokens := collectTokens(l)
+
+	expected := []token.TokenType{token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON, token.IDENT, token.PLUS, token.EOF}
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d", len(expected), len(tokens))
+	}
+	for i, tt := range expected {
+		if tokens[i].Type != tt {
+			t.Errorf("tokens[%d] wrong. expected=%q, got=%q", i, tt, tokens[i].Type)
+		}
+	}
+	errors := l.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error. got=%d (%v)", len(errors), errors)
+	}
+	if errors[0].Code != diagnostic.ReadError || errors[0].Message != "read error: disk on fire" || errors[0].Pos.String() != "2:4" {
+		t.Errorf("wrong error. got=%s", errors[0])
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 10;\n  x == \"ab\""
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  token.Position
+		expectedEnd  token.Position
+	}{
+		{token.LET, token.Position{Filename: "a.mk", Offset: 0, Line: 1, Column: 1}, token.Position{Filename: "a.mk", Offset: 3, Line: 1, Column: 4}},
+		{token.IDENT, token.Position{Filename: "a.mk", Offset: 4, Line: 1, Column: 5}, token.Position{Filename: "a.mk", Offset: 5, Line: 1, Column: 6}},
+		{token.ASSIGN, token.Position{Filename: "a.mk", Offset: 6, Line: 1, Column: 7}, token.Position{Filename: "a.mk", Offset: 7, Line: 1, Column: 8}},
+		{token.INT, token.Position{Filename: "a.mk", Offset: 8, Line: 1, Column: 9}, token.Position{Filename: "a.mk", Offset: 10, Line: 1, Column: 11}},
+		{token.SEMICOLON, token.Position{Filename: "a.mk", Offset: 10, Line: 1, Column: 11}, token.Position{Filename: "a.mk", Offset: 11, Line: 1, Column: 12}},
+		{token.IDENT, token.Position{Filename: "a.mk", Offset: 14, Line: 2, Column: 3}, token.Position{Filename: "a.mk", Offset: 15, Line: 2, Column: 4}},
+		{token.EQ, token.Position{Filename: "a.mk", Offset: 16, Line: 2, Column: 5}, token.Position{Filename: "a.mk", Offset: 18, Line: 2, Column: 7}},
+		{token.STRING, token.Position{Filename: "a.mk", Offset: 19, Line: 2, Column: 8}, token.Position{Filename: "a.mk", Offset: 23, Line: 2, Column: 12}},
+		{token.EOF, token.Position{Filename: "a.mk", Offset: 23, Line: 2, Column: 12}, token.Position{Filename: "a.mk", Offset: 23, Line: 2, Column: 12}},
+		{token.EOF, token.Position{Filename: "a.mk", Offset: 23, Line: 2, Column: 12}, token.Position{Filename: "a.mk", Offset: 23, Line: 2, Column: 12}},
+	}
+
+	l := NewFile("a.mk", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Errorf("test[%d] - pos wrong. expected=%+v, got=%+v", i, tt.expectedPos, tok.Pos)
+		}
+		if tok.End != tt.expectedEnd {
+			t.Errorf("test[%d] - end wrong. expected=%+v, got=%+v", i, tt.expectedEnd, tok.End)
+		}
+	}
+}
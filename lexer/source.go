@@ -0,0 +1,68 @@
+package lexer
+
+import (
+	"bufio"
+	"io"
+	"unicode/utf8"
+)
+
+// bufferSize 缓冲区每次至少能容纳的字节数
+const bufferSize = 4096
+
+// source 词法分析器的输入缓冲区，只保存从当前token开头到已读入部分的源码，
+// 因此分析大文件或管道输入时不需要将源码全部读入内存。所有位置都是源码中的字节偏移量
+type source struct {
+	reader *bufio.Reader
+	buf    []byte // 已经读入、且可能仍会被用到的源码
+	start  int    // buf[0]在源码中的偏移量
+	keep   int    // 此偏移量之前的源码已经不再需要，可以丢弃
+	eof    bool   // reader已经读完或者读取出错
+	err    error  // 读取出错时的错误，正常读到结尾时为nil
+}
+
+// decode 解码offset处的字符，返回字符及其字节宽度，已经到达源码结尾时宽度为0
+func (s *source) decode(offset int) (rune, int) {
+	s.fill(offset + utf8.UTFMax)
+	i := offset - s.start
+	if i >= len(s.buf) {
+		return 0, 0
+	}
+	return utf8.DecodeRune(s.buf[i:])
+}
+
+// text 返回源码中[start, end)之间的文本
+func (s *source) text(start, end int) string {
+	return string(s.buf[start-s.start : end-s.start])
+}
+
+// release 表示offset之前的源码不会再被用到
+func (s *source) release(offset int) {
+	s.keep = offset
+}
+
+// fill 从reader中读取源码，直到end之前的字节都已经读入，或者读完了全部源码
+func (s *source) fill(end int) {
+	for !s.eof && s.start+len(s.buf) < end {
+		if cap(s.buf)-len(s.buf) < bufferSize {
+			// 先丢弃不再需要的部分，空间仍然不够时再扩容
+			if drop := s.keep - s.start; drop > 0 {
+				n := copy(s.buf, s.buf[drop:])
+				s.buf = s.buf[:n]
+				s.start = s.keep
+			}
+			if cap(s.buf)-len(s.buf) < bufferSize {
+				buf := make([]byte, len(s.buf), 2*cap(s.buf)+bufferSize)
+				copy(buf, s.buf)
+				s.buf = buf
+			}
+		}
+		n, err := s.reader.Read(s.buf[len(s.buf):cap(s.buf)])
+		s.buf = s.buf[:len(s.buf)+n]
+		if err != nil {
+			s.eof = true
+			if err != io.EOF {
+				s.err = err
+			}
+		}
+	}
+}
@@ -30,3 +30,16 @@ func (e *Environment) Set(name string, obj Object) Object {
 	e.store[name] = obj
 	return obj
 }
+
+// Assign 修改已存在的变量，与Get一样由内向外查找，在变量所在的作用域中修改，变量不存在时返回false
+// 与Set不同，Set总是在当前作用域中绑定（即let），Assign使闭包能够修改外层作用域中的变量
+func (e *Environment) Assign(name string, obj Object) (Object, bool) {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = obj
+		return obj, true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, obj)
+	}
+	return nil, false
+}
@@ -2,9 +2,14 @@ package object
 
 import (
 	"Monkey_1/ast"
+	"Monkey_1/token"
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"math/big"
+	"strconv"
 	"strings"
 )
 
@@ -12,15 +17,21 @@ type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
+	BIGINT_OBJ       = "BIGINT"
 	STRING_OBJ       = "STRING"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+	TAIL_CALL_OBJ    = "TAIL_CALL"
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION"
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	RANGE_OBJ        = "RANGE"
 )
 
 // 每个值有不同表现形式，因此使用 Object 接口会比使用多个字段的结构体简洁
@@ -38,6 +49,32 @@ func (i *Integer) Inspect() string { return fmt.Sprintf("%d", i.Value) }
 
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 
+// BigInt ##############################################
+// BigInt 任意精度整数，只用来表示超出int64范围的整数，范围内的整数总是用Integer表示
+type BigInt struct {
+	Value *big.Int // 不可修改，运算总是产生新的big.Int
+}
+
+func (bi *BigInt) Inspect() string { return bi.Value.String() }
+
+func (bi *BigInt) Type() ObjectType { return BIGINT_OBJ }
+
+// Float ##############################################
+type Float struct {
+	Value float64
+}
+
+// Inspect 整数值的浮点数也带上小数点，以便与Integer区分，如 2.0
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEnN") { // NaN, +Inf, -Inf 中含有n或N
+		s += ".0"
+	}
+	return s
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
 // Boolean ##############################################
 type Boolean struct {
 	Value bool // 使用宿主语言的原生类型
@@ -63,18 +100,94 @@ func (rv *ReturnValue) Inspect() string { return rv.Value.Inspect() }
 
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 
+// Break #################################################
+// Break 与 ReturnValue 类似，是循环控制的信号，从块语句中一直向外传递到所在的循环
+type Break struct{}
+
+func (b *Break) Inspect() string { return "break" }
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+
+// Continue #################################################
+type Continue struct{}
+
+func (c *Continue) Inspect() string { return "continue" }
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+
+// TailCall #################################################
+// TailCall 处于尾部位置的函数调用，函数和实参都已求值但还没有执行
+// 与 ReturnValue 类似，它从函数体中传递到applyFunction，由其在循环中执行，从而不增加宿主语言的调用栈
+type TailCall struct {
+	Function  Object
+	Arguments []Object
+	Call      *ast.CallExpression // 调用处，用于记录错误的位置和调用栈
+}
+
+func (tc *TailCall) Inspect() string { return "tail call " + tc.Call.String() }
+
+func (tc *TailCall) Type() ObjectType { return TAIL_CALL_OBJ }
+
 // Error #################################################
 type Error struct {
-	Message string // 只返回了错误信息，无法返回行号列号
+	Message string
+	Pos     token.Position // 出错的位置
+	Stack   []Frame        // 错误向外传播时经过的函数调用，Stack[0]是最内层的调用
+	Cause   error          // 导致该错误的宿主语言中的错误，如求值被取消时的ctx.Err()，一般为nil
+}
+
+// Frame 调用栈中的一帧
+type Frame struct {
+	Function string         // 被调用的函数名，匿名函数为其字面值
+	Pos      token.Position // 调用处的位置
 }
 
 func (e *Error) Inspect() string { return "ERROR: " + e.Message }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 
+// Error 实现error接口，以便宿主程序像处理Go的错误一样处理它
+func (e *Error) Error() string { return e.Message }
+
+// Unwrap 返回Cause，以便用errors.Is和errors.As判断错误的原因
+func (e *Error) Unwrap() error { return e.Cause }
+
+// Trace 返回带调用栈的错误信息，每一行是一个函数以及错误在该函数中发生（或经过）的位置，最内层在前
+// 递归产生的连续相同的行只打印一次，并注明重复的次数
+func (e *Error) Trace() string {
+	var out bytes.Buffer
+	out.WriteString(e.Inspect())
+	pos := e.Pos
+	var last string
+	repeated := 0
+	for _, frame := range e.Stack {
+		line := fmt.Sprintf("\n    at %s (%s)", frame.Function, pos)
+		pos = frame.Pos // 外层函数中出错的位置就是调用内层函数的位置
+		if line == last {
+			repeated++
+			continue
+		}
+		writeRepeated(&out, repeated)
+		out.WriteString(line)
+		last, repeated = line, 0
+	}
+	writeRepeated(&out, repeated)
+	out.WriteString(fmt.Sprintf("\n    at <program> (%s)", pos))
+	return out.String()
+}
+
+func writeRepeated(out *bytes.Buffer, repeated int) {
+	if repeated > 0 {
+		out.WriteString(fmt.Sprintf("\n    ... previous line repeated %d more times", repeated))
+	}
+}
+
 // Function #################################################
 type Function struct {
+	Name       string // 函数名，仅用于调用栈，匿名函数为空
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // 与Parameters一一对应的默认值，没有默认值的参数为nil，调用时才求值
+	Rest       *ast.Identifier  // 剩余参数，多余的实参以Array的形式绑定到它上面，没有则为nil
 	Body       *ast.BlockStatement
 	Env        *Environment // 目前理解为作用域
 }
@@ -82,13 +195,9 @@ type Function struct {
 func (f *Function) Inspect() string {
 	// 返回函数的字面值
 	var out bytes.Buffer
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
 	out.WriteString("fn")
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ast.FormatParameters(f.Parameters, f.Defaults, f.Rest))
 	out.WriteString(") {\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
@@ -97,9 +206,33 @@ func (f *Function) Inspect() string {
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 
+// Signature 返回函数名，匿名函数返回形如 fn(x, y) 的字面值
+func (f *Function) Signature() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return "fn(" + ast.FormatParameters(f.Parameters, f.Defaults, f.Rest) + ")"
+}
+
+// Arity 返回函数接受的实参数量范围，max为-1表示有剩余参数，数量没有上限
+func (f *Function) Arity() (min, max int) {
+	min = len(f.Parameters)
+	for i, d := range f.Defaults {
+		if d != nil {
+			min = i // 有默认值的参数都在必填参数之后
+			break
+		}
+	}
+	if f.Rest != nil {
+		return min, -1
+	}
+	return min, len(f.Parameters)
+}
+
 // String #################################################
 type String struct {
-	Value string // 只返回了错误信息，无法返回行号列号
+	Value   string   // 只返回了错误信息，无法返回行号列号
+	hashKey *HashKey // HashKey()的缓存
 }
 
 func (s *String) Inspect() string { return s.Value }
@@ -107,7 +240,16 @@ func (s *String) Inspect() string { return s.Value }
 func (s *String) Type() ObjectType { return STRING_OBJ }
 
 // ------
-type BuiltinFunction func(args ...Object) Object
+// Allocator 由解释器实现，内置函数通过它记录新分配的对象，以便解释器限制分配的数量和字节数
+type Allocator interface {
+	// Allocate 记录新分配的对象obj，超出预算时返回错误，否则返回obj本身
+	Allocate(obj Object) Object
+	// Charge 在分配之前记录将要分配count个对象、共size字节，超出预算时返回错误
+	Charge(count, size int64) *Error
+}
+
+// BuiltinFunction 内置函数，返回新分配的对象时需要经过alloc记录
+type BuiltinFunction func(alloc Allocator, args ...Object) Object
 
 type Builtin struct {
 	Fn BuiltinFunction
@@ -120,20 +262,51 @@ func (b *Builtin) Inspect() string { return "builtin function" }
 // ArrayLiteral
 type Array struct {
 	Elements []Object
+	Frozen   bool // 不可变数组（元组），元素不能被重新赋值，元素都能作为键时它也能作为哈希表的键
 }
 
 func (a *Array) Type() ObjectType { return ARRAY_OBJ }
 
-func (a *Array) Inspect() string {
-	var out bytes.Buffer
-	var elements []string
-	for _, e := range a.Elements {
-		elements = append(elements, e.Inspect())
+func (a *Array) Inspect() string { return inspect(a, map[Object]bool{}) }
+
+// Range 由内置函数range创建的整数序列 [Start, End)，步长为Step，不会预先生成所有元素
+type Range struct {
+	Start int64
+	End   int64
+	Step  int64 // 不为0，为负数时序列递减
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+
+func (r *Range) Inspect() string {
+	if r.Step == 1 {
+		return fmt.Sprintf("range(%d, %d)", r.Start, r.End)
 	}
-	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ","))
-	out.WriteString("]")
-	return out.String()
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.End, r.Step)
+}
+
+// Len 返回序列中元素的数量
+// 使用无符号数计算跨度，避免 range(-9223372036854775808, 9223372036854775807) 这样的序列溢出
+func (r *Range) Len() uint64 {
+	var span, step uint64
+	switch {
+	case r.Step > 0 && r.Start < r.End:
+		span, step = uint64(r.End)-uint64(r.Start), uint64(r.Step)
+	case r.Step < 0 && r.Start > r.End:
+		span, step = uint64(r.Start)-uint64(r.End), -uint64(r.Step)
+	default:
+		return 0
+	}
+	n := span / step
+	if span%step != 0 {
+		n++
+	}
+	return n
+}
+
+// At 返回序列中的第i个元素，调用前需要保证 i < Len()
+func (r *Range) At(i uint64) int64 {
+	return int64(uint64(r.Start) + i*uint64(r.Step))
 }
 
 type HashKey struct {
@@ -156,11 +329,54 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
-// todo: 通过缓存HashKey()⽅法的返回值来优化其性能
+func (bi *BigInt) HashKey() HashKey {
+	return bigIntHashKey(bi.Value)
+}
+
+func bigIntHashKey(value *big.Int) HashKey {
+	h := fnv.New64a()
+	if value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+	h.Write(value.Bytes())
+	return HashKey{Type: BIGINT_OBJ, Value: h.Sum64()}
+}
+
+// HashKey 值为整数的浮点数与对应的整数是同一个键，因为 1 == 1.0，所以 {1: "a"}[1.0] 应该能找到"a"
+// 超出int64范围的如1e19，与相等的BigInt是同一个键
+func (f *Float) HashKey() HashKey {
+	switch {
+	case f.Value != math.Trunc(f.Value) || math.IsInf(f.Value, 0):
+		return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+	case f.Value >= math.MinInt64 && f.Value < math.MaxInt64:
+		return HashKey{Type: INTEGER_OBJ, Value: uint64(int64(f.Value))}
+	default:
+		i, _ := big.NewFloat(f.Value).Int(nil)
+		return bigIntHashKey(i)
+	}
+}
+
+// HashKey 结果缓存在hashKey中，Value不会被修改，所以缓存不会失效
 func (s *String) HashKey() HashKey {
-	h := fnv.New64a() // 可能出现哈希碰撞，todo: 单链法和开放式寻址法
-	h.Write([]byte(s.Value))
-	return HashKey{Type: s.Type(), Value: h.Sum64()}
+	if s.hashKey == nil {
+		h := fnv.New64a() // 不同的字符串可能碰撞，由Hash比较键本身来区分
+		h.Write([]byte(s.Value))
+		s.hashKey = &HashKey{Type: s.Type(), Value: h.Sum64()}
+	}
+	return *s.hashKey
+}
+
+// HashKey 由各个元素的HashKey组合而成，调用前需要保证AsHashable(a)成立
+func (a *Array) HashKey() HashKey {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	for _, e := range a.Elements {
+		key := e.(Hashable).HashKey()
+		h.Write([]byte(key.Type))
+		binary.LittleEndian.PutUint64(buf, key.Value)
+		h.Write(buf)
+	}
+	return HashKey{Type: a.Type(), Value: h.Sum64()}
 }
 
 type HashPair struct {
@@ -168,25 +384,180 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash 哈希表，遍历和打印都按照键的插入顺序进行
+// HashKey相同的键（哈希碰撞）用单链法区分：同一个HashKey下记录多个键值对，查找时比较键本身
+// 修改时需要使用Set，以便维护插入顺序和索引。零值 &Hash{} 是可以使用的空哈希表
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	Pairs   []HashPair        // 按插入顺序排列的键值对，已有的键被重新赋值时位置不变
+	buckets map[HashKey][]int // HashKey相同的键值对在Pairs中的下标
+}
+
+// NewHash 创建一个空的哈希表
+func NewHash() *Hash {
+	return &Hash{buckets: make(map[HashKey][]int)}
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
 
-func (h *Hash) Inspect() string {
+// Get 查找键对应的键值对
+func (h *Hash) Get(key Hashable) (HashPair, bool) {
+	if i, ok := h.index(key); ok {
+		return h.Pairs[i], true
+	}
+	return HashPair{}, false
+}
+
+// Set 设置键对应的值，新的键排在最后
+func (h *Hash) Set(key Hashable, value Object) {
+	if i, ok := h.index(key); ok {
+		h.Pairs[i].Value = value
+		return
+	}
+	hashKey := key.HashKey()
+	h.buckets[hashKey] = append(h.buckets[hashKey], len(h.Pairs))
+	h.Pairs = append(h.Pairs, HashPair{Key: key, Value: value})
+}
+
+// index 返回键在Pairs中的下标，先按HashKey找到链，再逐个比较键
+func (h *Hash) index(key Hashable) (int, bool) {
+	if h.buckets == nil {
+		h.buildBuckets()
+	}
+	for _, i := range h.buckets[key.HashKey()] {
+		if keysEqual(h.Pairs[i].Key, key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// buildBuckets 根据Pairs建立索引，使不经过NewHash创建的哈希表（如 &Hash{}）也可以使用
+func (h *Hash) buildBuckets() {
+	h.buckets = make(map[HashKey][]int, len(h.Pairs))
+	for i, pair := range h.Pairs {
+		hashKey := pair.Key.(Hashable).HashKey()
+		h.buckets[hashKey] = append(h.buckets[hashKey], i)
+	}
+}
+
+// keysEqual 判断HashKey相同的两个键是否真的是同一个键
+func keysEqual(a, b Object) bool {
+	switch a := a.(type) {
+	case *Integer:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == b.Value
+		case *Float:
+			return floatEqualsInteger(b.Value, a.Value)
+		}
+	case *Float:
+		switch b := b.(type) {
+		case *Integer:
+			return floatEqualsInteger(a.Value, b.Value)
+		case *Float:
+			// NaN作为键时也要能找到自己
+			return a.Value == b.Value || math.Float64bits(a.Value) == math.Float64bits(b.Value)
+		case *BigInt:
+			return floatEqualsBigInt(a.Value, b.Value)
+		}
+	case *BigInt:
+		switch b := b.(type) {
+		case *BigInt:
+			return a.Value.Cmp(b.Value) == 0
+		case *Float:
+			return floatEqualsBigInt(b.Value, a.Value)
+		}
+	case *String:
+		if b, ok := b.(*String); ok {
+			return a.Value == b.Value
+		}
+	case *Boolean:
+		if b, ok := b.(*Boolean); ok {
+			return a.Value == b.Value
+		}
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !keysEqual(a.Elements[i], b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// floatEqualsInteger 判断浮点数是否与整数相等，与Float.HashKey()一样先转换为int64，避免int64转float64时的精度损失
+func floatEqualsInteger(f float64, i int64) bool {
+	return f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 && int64(f) == i
+}
+
+func floatEqualsBigInt(f float64, i *big.Int) bool {
+	return f == math.Trunc(f) && !math.IsInf(f, 0) && big.NewFloat(f).Cmp(new(big.Float).SetInt(i)) == 0
+}
+
+func (h *Hash) Inspect() string { return inspect(h, map[Object]bool{}) }
+
+// inspect 返回数组和哈希表的字符串表示，open记录正在输出的外层容器
+// 通过索引赋值，容器可以包含自身，如 let a = [1]; a[0] = a，再次遇到时输出 [...] 或 {...}，避免无穷递归
+func inspect(obj Object, open map[Object]bool) string {
 	var out bytes.Buffer
-	var pairs []string
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s",
-			pair.Key.Inspect(), pair.Value.Inspect()))
-	}
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("}")
+	switch obj := obj.(type) {
+	case *Array:
+		if open[obj] {
+			return "[...]"
+		}
+		open[obj] = true
+		defer delete(open, obj)
+		var elements []string
+		for _, e := range obj.Elements {
+			elements = append(elements, inspect(e, open))
+		}
+		out.WriteString("[")
+		out.WriteString(strings.Join(elements, ","))
+		out.WriteString("]")
+	case *Hash:
+		if open[obj] {
+			return "{...}"
+		}
+		open[obj] = true
+		defer delete(open, obj)
+		var pairs []string
+		for _, pair := range obj.Pairs {
+			pairs = append(pairs, fmt.Sprintf("%s: %s",
+				inspect(pair.Key, open), inspect(pair.Value, open)))
+		}
+		out.WriteString("{")
+		out.WriteString(strings.Join(pairs, ", "))
+		out.WriteString("}")
+	default:
+		return obj.Inspect()
+	}
 	return out.String()
 }
 
+// Hashable 可以作为哈希表键的对象
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
+
+// AsHashable 判断对象能否作为哈希表的键
+// 数组虽然实现了Hashable，但只有不可变且所有元素都能作为键时才可以，否则修改数组后就再也找不到它对应的值了
+func AsHashable(obj Object) (Hashable, bool) {
+	if array, ok := obj.(*Array); ok {
+		if !array.Frozen {
+			return nil, false
+		}
+		for _, e := range array.Elements {
+			if _, ok := AsHashable(e); !ok {
+				return nil, false
+			}
+		}
+	}
+	key, ok := obj.(Hashable)
+	return key, ok
+}
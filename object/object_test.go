@@ -1,6 +1,11 @@
 package object
 
-import "testing"
+import (
+	"Monkey_1/token"
+	"math"
+	"math/big"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -17,3 +22,173 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestFloatHashKey(t *testing.T) {
+	if (&Float{Value: 2.0}).HashKey() != (&Integer{Value: 2}).HashKey() {
+		t.Errorf("integral float and integer with same value have different hash keys")
+	}
+	if (&Float{Value: 2.5}).HashKey() != (&Float{Value: 2.5}).HashKey() {
+		t.Errorf("floats with same value have different hash keys")
+	}
+	if (&Float{Value: 2.5}).HashKey() == (&Float{Value: 3.5}).HashKey() {
+		t.Errorf("floats with different value have same hash keys")
+	}
+	if (&Float{Value: math.Copysign(0, -1)}).HashKey() != (&Integer{Value: 0}).HashKey() {
+		t.Errorf("negative zero and zero have different hash keys")
+	}
+	big19, _ := new(big.Int).SetString("10000000000000000000", 10)
+	if (&Float{Value: 1e19}).HashKey() != (&BigInt{Value: big19}).HashKey() {
+		t.Errorf("integral float and big integer with same value have different hash keys")
+	}
+	if (&Float{Value: math.Inf(1)}).HashKey() == (&Float{Value: math.Inf(-1)}).HashKey() {
+		t.Errorf("positive and negative infinity have same hash keys")
+	}
+}
+
+func TestStringHashKeyCached(t *testing.T) {
+	s := &String{Value: "cached"}
+	first := s.HashKey()
+	if s.hashKey == nil || *s.hashKey != first {
+		t.Fatalf("hash key was not cached")
+	}
+	if s.HashKey() != first {
+		t.Errorf("cached hash key differs from the first one")
+	}
+}
+
+func TestHashCollision(t *testing.T) {
+	// 人为制造两个HashKey相同但内容不同的字符串
+	collided := HashKey{Type: STRING_OBJ, Value: 42}
+	a := &String{Value: "a", hashKey: &collided}
+	b := &String{Value: "b", hashKey: &collided}
+
+	hash := NewHash()
+	hash.Set(a, &Integer{Value: 1})
+	hash.Set(b, &Integer{Value: 2})
+	if len(hash.Pairs) != 2 {
+		t.Fatalf("colliding keys overwrote each other. got=%d pairs", len(hash.Pairs))
+	}
+
+	tests := []struct {
+		key      Hashable
+		expected int64
+	}{
+		{a, 1},
+		{b, 2},
+		{&String{Value: "a", hashKey: &collided}, 1},
+		{&String{Value: "b", hashKey: &collided}, 2},
+	}
+	for _, tt := range tests {
+		pair, ok := hash.Get(tt.key)
+		if !ok {
+			t.Fatalf("no pair for key %q", tt.key.Inspect())
+		}
+		if pair.Value.(*Integer).Value != tt.expected {
+			t.Errorf("wrong value for key %q. expected=%d, got=%s",
+				tt.key.Inspect(), tt.expected, pair.Value.Inspect())
+		}
+	}
+
+	if _, ok := hash.Get(&String{Value: "c", hashKey: &collided}); ok {
+		t.Errorf("found a pair for a key that was never set")
+	}
+
+	hash.Set(&String{Value: "a", hashKey: &collided}, &Integer{Value: 3})
+	if len(hash.Pairs) != 2 || hash.Pairs[0].Value.(*Integer).Value != 3 {
+		t.Errorf("reassigning a colliding key failed. got=%s", hash.Inspect())
+	}
+}
+
+func TestHashNumericKeys(t *testing.T) {
+	hash := NewHash()
+	hash.Set(&Integer{Value: 1}, &String{Value: "int"})
+	hash.Set(&Float{Value: 1.0}, &String{Value: "float"})
+	hash.Set(&Float{Value: math.NaN()}, &String{Value: "nan"})
+	if len(hash.Pairs) != 2 {
+		t.Fatalf("wrong num of pairs. got=%s", hash.Inspect())
+	}
+	if pair, ok := hash.Get(&Integer{Value: 1}); !ok || pair.Value.Inspect() != "float" {
+		t.Errorf("1 and 1.0 should be the same key. got=%s", hash.Inspect())
+	}
+	if _, ok := hash.Get(&Float{Value: math.NaN()}); !ok {
+		t.Errorf("NaN key not found")
+	}
+	big19, _ := new(big.Int).SetString("10000000000000000000", 10)
+	hash.Set(&Float{Value: 1e19}, &String{Value: "float"})
+	if pair, ok := hash.Get(&BigInt{Value: big19}); !ok || pair.Value.Inspect() != "float" {
+		t.Errorf("1e19 and 10000000000000000000 should be the same key. got=%s", hash.Inspect())
+	}
+}
+
+func TestInspectCycles(t *testing.T) {
+	a := &Array{Elements: []Object{&Integer{Value: 1}}}
+	a.Elements[0] = a
+	if a.Inspect() != "[[...]]" {
+		t.Errorf("wrong Inspect() for cyclic array. got=%q", a.Inspect())
+	}
+	h := NewHash()
+	h.Set(&String{Value: "self"}, h)
+	h.Set(&String{Value: "list"}, &Array{Elements: []Object{h, a}})
+	if h.Inspect() != "{self: {...}, list: [{...},[[...]]]}" {
+		t.Errorf("wrong Inspect() for cyclic hash. got=%q", h.Inspect())
+	}
+}
+
+func TestHashZeroValue(t *testing.T) {
+	hash := &Hash{}
+	if _, ok := hash.Get(&String{Value: "a"}); ok {
+		t.Errorf("empty hash should not contain any key")
+	}
+	hash.Set(&String{Value: "a"}, &Integer{Value: 1})
+	hash.Set(&String{Value: "a"}, &Integer{Value: 2})
+	if pair, ok := hash.Get(&String{Value: "a"}); !ok || len(hash.Pairs) != 1 || pair.Value.Inspect() != "2" {
+		t.Errorf("wrong pairs in zero value hash. got=%s", hash.Inspect())
+	}
+
+	// 直接给出Pairs的哈希表也能找到其中的键
+	hash = &Hash{Pairs: []HashPair{{Key: &Integer{Value: 1}, Value: &String{Value: "one"}}}}
+	if pair, ok := hash.Get(&Float{Value: 1.0}); !ok || pair.Value.Inspect() != "one" {
+		t.Errorf("key in Pairs not found. got=%s", hash.Inspect())
+	}
+}
+
+func TestArrayHashKey(t *testing.T) {
+	a := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}, Frozen: true}
+	b := &Array{Elements: []Object{&Float{Value: 1.0}, &String{Value: "a"}}, Frozen: true}
+	c := &Array{Elements: []Object{&String{Value: "a"}, &Integer{Value: 1}}, Frozen: true}
+	if a.HashKey() != b.HashKey() {
+		t.Errorf("equal tuples have different hash keys")
+	}
+	if a.HashKey() == c.HashKey() {
+		t.Errorf("tuples with different element order have same hash keys")
+	}
+	if _, ok := AsHashable(&Array{Elements: []Object{&Integer{Value: 1}}}); ok {
+		t.Errorf("mutable array should not be hashable")
+	}
+	nested := &Array{Elements: []Object{&Array{Elements: []Object{}}}, Frozen: true}
+	if _, ok := AsHashable(nested); ok {
+		t.Errorf("tuple containing a mutable array should not be hashable")
+	}
+}
+
+func TestErrorTraceCollapsesRepeatedFrames(t *testing.T) {
+	pos := func(line, column int) token.Position { return token.Position{Line: line, Column: column} }
+	err := &Error{
+		Message: "boom",
+		Pos:     pos(1, 5),
+		Stack: []Frame{
+			{Function: "g", Pos: pos(2, 3)},
+			{Function: "f", Pos: pos(2, 3)},
+			{Function: "f", Pos: pos(2, 3)},
+			{Function: "f", Pos: pos(4, 1)},
+		},
+	}
+	expected := `ERROR: boom
+    at g (1:5)
+    at f (2:3)
+    ... previous line repeated 2 more times
+    at <program> (4:1)`
+	if err.Trace() != expected {
+		t.Errorf("wrong trace. expected=\n%s\ngot=\n%s", expected, err.Trace())
+	}
+}
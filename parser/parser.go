@@ -2,37 +2,61 @@ package parser
 
 import (
 	"Monkey_1/ast"
+	"Monkey_1/diagnostic"
 	"Monkey_1/lexer"
 	"Monkey_1/token"
+	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
 // 优先级
 const (
 	_int        = iota // 空白标识符
 	LOWEST             // 最低，任何表达式的开始都是最低优先级
-	EQUALS             // ==
-	LESSGREATER        // < or >
-	SUM                // +
-	PRODUCT            // *
+	ASSIGN             // = += -= *= /=
+	LOGICAL_OR         // ||
+	LOGICAL_AND        // &&
+	EQUALS             // == !=
+	LESSGREATER        // < > <= >=
+	SUM                // + - | ^
+	PRODUCT            // * / % & << >>
 	PREFIX             // -X or !X
+	POWER              // **，比前缀运算符更高，-2 ** 2 等价于 -(2 ** 2)
 	CALL               // myFunction(X)
 	INDEX
 )
 
 // token与优先级映射
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.OR:              LOGICAL_OR,
+	token.AND:             LOGICAL_AND,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.PIPE:            SUM,
+	token.CARET:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.AMPERSAND:       PRODUCT,
+	token.SHL:             PRODUCT,
+	token.SHR:             PRODUCT,
+	token.POWER:           POWER,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
 }
 
 type (
@@ -44,10 +68,15 @@ type (
 
 // Parser 语法解析器
 type Parser struct {
-	l         *lexer.Lexer // 词法解析器，依靠词法解析器可以检查并移动当前和后一个token
-	errors    []string     // 记录解析出现的所有的错误，不会因为出现一个错误停止解析
-	curToken  token.Token  // 当前的token
-	peekToken token.Token  // 后一个token，辅助决策
+	l         *lexer.Lexer            // 词法解析器，依靠词法解析器可以检查并移动当前和后一个token
+	errors    []diagnostic.Diagnostic // 记录解析出现的所有的错误，不会因为出现一个错误停止解析
+	curToken  token.Token             // 当前的token
+	peekToken token.Token             // 后一个token，辅助决策
+
+	lexErrors  int  // 已经转存到errors中的词法错误数量
+	panicMode  bool // 当前语句已经出错，在同步到语句边界之前不再记录新的错误，避免一个错误引发一连串错误
+	blockDepth int  // 当前所在块语句的嵌套层数，用于同步时判断 } 是否是语句边界
+	loopDepth  int  // 当前所在循环的嵌套层数，用于检查break和continue是否在循环中，进入函数体时清零
 
 	prefixParseFns map[token.TokenType]prefixParseFn // 记录不同tokenType对应的前缀表达式解析函数
 	infixParseFns  map[token.TokenType]infixParseFn  // 记录不同tokenType对应的中缀表达式解析函数
@@ -67,7 +96,7 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{}, // 记录所有错误
+		errors: []diagnostic.Diagnostic{}, // 记录所有错误
 	}
 	// 初始化，移动两次，cur为第0个token，peek为第1个token
 	p.nextToken()
@@ -75,9 +104,12 @@ func New(l *lexer.Lexer) *Parser {
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	// 注册前缀表达式
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.TEMPLATE_HEAD, p.parseInterpolatedString)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
@@ -101,7 +133,24 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.SHL, p.parseInfixExpression)
+	p.registerInfix(token.SHR, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	// 赋值表达式
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
 
 	// 解析调用表达式，属于中缀表达式解析函数
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
@@ -115,7 +164,10 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 	for p.curToken.Type != token.EOF {
 		stmt := p.parseStatement() // 每次解析一条语句
-		if stmt != nil {
+		if p.panicMode {
+			// 语句出错，跳到语句边界后继续解析，出错的语句不放入AST
+			p.synchronize()
+		} else if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
@@ -131,6 +183,12 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -154,6 +212,10 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	p.nextToken()
 	// 解析表达式
 	letStmt.Value = p.parseExpression(LOWEST)
+	// 记录函数名，使运行时错误的调用栈能显示函数名而不是字面值
+	if fl, ok := letStmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = letStmt.Name.Value
+	}
 	// 循环剩余的token，直到是分号，这是因为parseExpression不移动token。
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -177,6 +239,103 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return returnStmt
 }
 
+// parseWhileStatement 解析 while (<条件>) { <循环体> }
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	if stmt.Body = p.parseLoopBody(); stmt.Body == nil {
+		return nil
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseForStatement 解析 for (<变量> in <可迭代对象>) { <循环体> }
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	if stmt.Body = p.parseLoopBody(); stmt.Body == nil {
+		return nil
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseLoopBody 解析循环体，循环体中才可以使用break和continue，缺少 } 时返回nil
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+	body := p.parseBlockStatement()
+	if !p.curTokenIs(token.RBRACE) {
+		p.error(diagnostic.Diagnostic{
+			Code:       diagnostic.UnexpectedToken,
+			Message:    fmt.Sprintf("expected curToken to be %s, got %s instead", token.RBRACE, tokenName(p.curToken.Type)),
+			Pos:        p.curToken.Pos,
+			End:        p.curToken.End,
+			Expected:   []token.TokenType{token.RBRACE},
+			Actual:     p.curToken.Type,
+			Suggestion: fmt.Sprintf("insert %q to close the loop body", token.RBRACE),
+		})
+		return nil
+	}
+	return body
+}
+
+// parseLoopControlStatement 解析break或continue语句
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	if p.loopDepth == 0 {
+		p.error(diagnostic.Diagnostic{
+			Code:    diagnostic.InvalidBreak,
+			Message: fmt.Sprintf("%s outside loop", p.curToken.Literal),
+			Pos:     p.curToken.Pos,
+			End:     p.curToken.End,
+			Actual:  p.curToken.Type,
+		})
+		return nil
+	}
+	var stmt ast.Statement
+	if p.curTokenIs(token.BREAK) {
+		stmt = &ast.BreakStatement{Token: p.curToken}
+	} else {
+		stmt = &ast.ContinueStatement{Token: p.curToken}
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
 // parseExpressionStatement 解析表达式语句，即除了Let和Return后的语句
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	//defer untrace(trace("parseExpressionStatement"))
@@ -247,6 +406,10 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	}
 	// 保留当前解析的token的优先级，因为接下来要移动token
 	precedence := p.curPrecedence()
+	if precedence == POWER {
+		// 乘方是右结合的，2 ** 3 ** 2 等价于 2 ** (3 ** 2)
+		precedence--
+	}
 	// 移动token
 	p.nextToken()
 
@@ -256,6 +419,34 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// parseAssignExpression 解析赋值表达式，赋值是右结合的，a = b = 1 等价于 a = (b = 1)
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	if target == nil {
+		return nil // 左侧解析失败，错误已经记录
+	}
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		p.error(diagnostic.Diagnostic{
+			Code:    diagnostic.InvalidAssign,
+			Message: fmt.Sprintf("cannot assign to %s", target.String()),
+			Pos:     target.Pos(),
+			End:     target.End(),
+			Actual:  p.curToken.Type,
+		})
+		return nil
+	}
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+	p.nextToken()
+	// 以低一级的优先级解析右侧，使右侧的赋值运算符先结合
+	expression.Value = p.parseExpression(ASSIGN - 1)
+	return expression
+}
+
 // ------------------------------------------------------------------------------
 // parseIdentifier 解析 标识符 表达式
 func (p *Parser) parseIdentifier() ast.Expression {
@@ -267,11 +458,23 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	//defer untrace(trace("parseIntegerLiteral"))
 
 	lit := &ast.IntegerLiteral{Token: p.curToken}
+	digits, base := splitIntegerLiteral(p.curToken.Literal)
 	// strconv包：字符串和数值类型的相互转换
-	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	value, err := strconv.ParseInt(digits, base, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		// 超出int64范围，使用任意精度整数
+		if bigValue, ok := new(big.Int).SetString(digits, base); ok {
+			return &ast.BigIntegerLiteral{Token: p.curToken, Value: bigValue}
+		}
+	}
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.error(diagnostic.Diagnostic{
+			Code:    diagnostic.InvalidInteger,
+			Message: fmt.Sprintf("could not parse %q as integer", p.curToken.Literal),
+			Pos:     p.curToken.Pos,
+			End:     p.curToken.End,
+			Actual:  p.curToken.Type,
+		})
 		return nil
 	}
 	// 实际值
@@ -279,11 +482,93 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+// splitIntegerLiteral 去掉整数字面量中的下划线和进制前缀，返回数字部分及其进制
+// 进制与词法分析器的判断保持一致：没有前缀的都是十进制，因此 010 是10而不是C语言中的八进制8
+func splitIntegerLiteral(literal string) (digits string, base int) {
+	digits = strings.ReplaceAll(literal, "_", "")
+	if len(digits) > 2 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'X':
+			return digits[2:], 16
+		case 'o', 'O':
+			return digits[2:], 8
+		case 'b', 'B':
+			return digits[2:], 2
+		}
+	}
+	return digits, 10
+}
+
+// parseFloatLiteral 解析 float 表达式
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		// 超出float64范围的字面量会得到±Inf，同样视为错误
+		p.error(diagnostic.Diagnostic{
+			Code:    diagnostic.InvalidFloat,
+			Message: fmt.Sprintf("could not parse %q as float", p.curToken.Literal),
+			Pos:     p.curToken.Pos,
+			End:     p.curToken.End,
+			Actual:  p.curToken.Type,
+		})
+		return nil
+	}
+	lit.Value = value
+	return lit
+}
+
+// parseIllegal ILLEGAL token 的错误已经由词法分析器记录，此处只需放弃当前语句
+func (p *Parser) parseIllegal() ast.Expression {
+	p.panicMode = true
+	return nil
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	//defer untrace(trace("parseStringLiteral"))
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// parseInterpolatedString 解析插值字符串，词法分析器已经将其拆分为
+// TEMPLATE_HEAD <表达式> (TEMPLATE_MIDDLE <表达式>)* TEMPLATE_TAIL
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+	for {
+		// curToken是TEMPLATE_HEAD或TEMPLATE_MIDDLE，空的字符串片段不放入Parts
+		if p.curToken.Literal != "" {
+			str.Parts = append(str.Parts, &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+		}
+		p.nextToken()
+		part := p.parseExpression(LOWEST)
+		if part == nil {
+			return nil
+		}
+		str.Parts = append(str.Parts, part)
+
+		if !p.peekTokenIs(token.TEMPLATE_MIDDLE) && !p.peekTokenIs(token.TEMPLATE_TAIL) {
+			p.error(diagnostic.Diagnostic{
+				Code:       diagnostic.UnexpectedToken,
+				Message:    fmt.Sprintf("expected } to close string interpolation, got %s instead", tokenName(p.peekToken.Type)),
+				Pos:        p.peekToken.Pos,
+				End:        p.peekToken.End,
+				Expected:   []token.TokenType{token.TEMPLATE_MIDDLE, token.TEMPLATE_TAIL},
+				Actual:     p.peekToken.Type,
+				Suggestion: fmt.Sprintf("insert %q before %q", token.RBRACE, p.peekToken.Literal),
+			})
+			return nil
+		}
+		p.nextToken()
+		if p.curTokenIs(token.TEMPLATE_TAIL) {
+			break
+		}
+	}
+	if p.curToken.Literal != "" {
+		str.Parts = append(str.Parts, &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+	}
+	str.Tail = p.curToken
+	return str
+}
+
 // parseBoolean 解析 bool
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
@@ -334,69 +619,136 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
 
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
 	p.nextToken()
 
 	// 不断parseStatement，直到遇⻅ } 或 token.EOF，这和ParseProgram很类似
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.panicMode {
+			p.synchronize()
+		} else if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	block.RBrace = p.curToken
 	return block
 }
 
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
+	// 函数体不属于外层的循环，其中的break和continue不能跳出外层循环
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = loopDepth }()
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
-	// 解析出的是标识符ast.Identifier列表
-	lit.Parameters = p.parseFunctionParameters()
+	// 解析出的是标识符ast.Identifier列表，以及默认值和剩余参数
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 	lit.Body = p.parseBlockStatement()
 	if !p.curTokenIs(token.RBRACE) {
-		msg := fmt.Sprintf("expected curToken to be %s, got %s instead", token.RBRACE, p.curToken.Literal) // --------------------------------------
-		p.errors = append(p.errors, msg)
+		p.error(diagnostic.Diagnostic{
+			Code:       diagnostic.UnexpectedToken,
+			Message:    fmt.Sprintf("expected curToken to be %s, got %s instead", token.RBRACE, tokenName(p.curToken.Type)),
+			Pos:        p.curToken.Pos,
+			End:        p.curToken.End,
+			Expected:   []token.TokenType{token.RBRACE},
+			Actual:     p.curToken.Type,
+			Suggestion: fmt.Sprintf("insert %q to close the function body", token.RBRACE),
+		})
 		return nil
 	}
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parseFunctionParameters 解析参数列表 (x, y = 10, ...rest)，结果存入lit，curToken从 ( 移动到 )
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
 
 	// 参数列表为空
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 	p.nextToken()
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	if !p.parseFunctionParameter(lit) {
+		return false
+	}
 
 	// 为什么不用expectedPeek？
 	// 因为它在没有peek到时会添加错误，而这里没有peek仅表示参数标识符已经解析完毕。
-	for p.peekTokenIs(token.COMMA) {
+	// 剩余参数必须是最后一个参数，其后只能是 )
+	for lit.Rest == nil && p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		if !p.parseFunctionParameter(lit) {
+			return false
+		}
 	}
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+	return p.expectPeek(token.RPAREN)
+}
+
+// parseFunctionParameter 解析单个参数：x、x = <默认值> 或 ...rest
+func (p *Parser) parseFunctionParameter(lit *ast.FunctionLiteral) bool {
+	if p.curTokenIs(token.ELLIPSIS) {
+		if !p.expectPeek(token.IDENT) {
+			return false
+		}
+		lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		return true
+	}
+	if !p.curTokenIs(token.IDENT) {
+		p.error(diagnostic.Diagnostic{
+			Code:     diagnostic.InvalidParam,
+			Message:  fmt.Sprintf("expected parameter name, got %s instead", tokenName(p.curToken.Type)),
+			Pos:      p.curToken.Pos,
+			End:      p.curToken.End,
+			Expected: []token.TokenType{token.IDENT, token.ELLIPSIS},
+			Actual:   p.curToken.Type,
+		})
+		return false
 	}
-	return identifiers
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	var defaultValue ast.Expression
+	if p.peekTokenIs(token.ASSIGN) {
+		p.nextToken()
+		p.nextToken()
+		defaultValue = p.parseExpression(LOWEST)
+		if defaultValue == nil {
+			return false
+		}
+	} else if len(lit.Defaults) > 0 && lit.Defaults[len(lit.Defaults)-1] != nil {
+		// 有默认值的参数之后不能再出现必填参数，否则实参与形参无法对应
+		p.error(diagnostic.Diagnostic{
+			Code:       diagnostic.InvalidParam,
+			Message:    fmt.Sprintf("parameter %s without default value follows parameter with default value", ident.Value),
+			Pos:        ident.Pos(),
+			End:        ident.End(),
+			Actual:     token.IDENT,
+			Suggestion: fmt.Sprintf("give %s a default value or move it before the parameters with default values", ident.Value),
+		})
+		return false
+	}
+	lit.Parameters = append(lit.Parameters, ident)
+	lit.Defaults = append(lit.Defaults, defaultValue)
+	return true
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseCallArguments()
+	exp.RParen = p.curToken
 	return exp
 }
 
@@ -429,6 +781,7 @@ func (p *Parser) parseArrayExpression() ast.Expression {
 	var elements []ast.Expression
 	if p.peekTokenIs(token.RBRACKET) {
 		p.nextToken()
+		array.RBracket = p.curToken
 		return array
 	}
 	p.nextToken()
@@ -444,22 +797,56 @@ func (p *Parser) parseArrayExpression() ast.Expression {
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	array.RBracket = p.curToken
 	return array
 }
 
 func (p *Parser) parseIndexExpression(ArrayIdentifier ast.Expression) ast.Expression {
 	indexArray := &ast.IndexExpression{Token: p.curToken, ArrayIdentifier: ArrayIdentifier}
+	// a[:stop] 省略了start，直接是切片
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpression(indexArray.Token, ArrayIdentifier, nil)
+	}
 	p.nextToken()
 	indexArray.Index = p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpression(indexArray.Token, ArrayIdentifier, indexArray.Index)
+	}
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	indexArray.RBracket = p.curToken
 	return indexArray
 }
 
+// parseSliceExpression 解析 a[start:stop:step] 中start之后的部分，调用时curToken是start的最后一个词法单元（或'['）
+func (p *Parser) parseSliceExpression(tok token.Token, ArrayIdentifier, start ast.Expression) ast.Expression {
+	slice := &ast.SliceExpression{Token: tok, ArrayIdentifier: ArrayIdentifier, Start: start}
+	p.nextToken() // 第一个 ':'
+	slice.Stop = p.parseSliceBound()
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		slice.Step = p.parseSliceBound()
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	slice.RBracket = p.curToken
+	return slice
+}
+
+// parseSliceBound 解析切片中 ':' 之后的一部分，省略时返回nil
+func (p *Parser) parseSliceBound() ast.Expression {
+	if p.peekTokenIs(token.COLON) || p.peekTokenIs(token.RBRACKET) {
+		return nil
+	}
+	p.nextToken()
+	return p.parseExpression(LOWEST)
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hashLiteral := &ast.HashLiteral{Token: p.curToken}
-	hashLiteral.Pairs = make(map[ast.Expression]ast.Expression)
+	hashLiteral.Pairs = []ast.HashPair{}
 	for !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
 		key := p.parseExpression(LOWEST)
@@ -468,7 +855,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		}
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
-		hashLiteral.Pairs[key] = value
+		hashLiteral.Pairs = append(hashLiteral.Pairs, ast.HashPair{Key: key, Value: value})
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -477,6 +864,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hashLiteral.RBrace = p.curToken
 	return hashLiteral
 }
 
@@ -485,6 +873,15 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	// 词法分析器可能处于保留注释的模式，注释对语法没有影响
+	for p.peekToken.Type == token.COMMENT {
+		p.peekToken = p.l.NextToken()
+	}
+	// 词法错误随token一同产生，及时转存，保证errors大致按源码顺序排列
+	if lexErrors := p.l.Errors(); len(lexErrors) > p.lexErrors {
+		p.errors = append(p.errors, lexErrors[p.lexErrors:]...)
+		p.lexErrors = len(lexErrors)
+	}
 }
 
 // curTokenIs 判断当前token的token.TokenType
@@ -528,19 +925,83 @@ func (p *Parser) curPrecedence() int {
 
 // ---------------- errors -------------------
 
-// Errors 返回Parser记录的Errors
-func (p *Parser) Errors() []string {
+// Errors 返回Parser记录的诊断信息，包括词法分析阶段的错误
+func (p *Parser) Errors() []diagnostic.Diagnostic {
 	return p.errors
 }
 
+// error 为Parser记录错误，并进入panicMode，直到同步到下一条语句前不再记录新的错误
+func (p *Parser) error(d diagnostic.Diagnostic) {
+	if p.panicMode {
+		return
+	}
+	p.panicMode = true
+	d.Severity = diagnostic.Error
+	p.errors = append(p.errors, d)
+}
+
+// synchronize 出错后跳过剩余的token，直到语句的边界：分号、下一条let/return/while/for语句、所在块的 } 或 EOF
+func (p *Parser) synchronize() {
+	p.panicMode = false
+	depth := 0 // 跳过的token中未闭合的 { 的数量，其中的分号和 } 都不是当前语句的边界
+	for !p.curTokenIs(token.EOF) {
+		if depth == 0 && p.curTokenIs(token.SEMICOLON) {
+			return
+		}
+		switch p.peekToken.Type {
+		case token.EOF:
+			return
+		case token.LET, token.RETURN, token.WHILE, token.FOR:
+			if depth == 0 {
+				return
+			}
+		case token.RBRACE:
+			if depth == 0 && p.blockDepth > 0 {
+				return
+			}
+		}
+		p.nextToken()
+		if p.curTokenIs(token.LBRACE) {
+			depth++
+		} else if p.curTokenIs(token.RBRACE) && depth > 0 {
+			depth--
+		}
+	}
+}
+
 // peekError 当期待的词法单元没匹配上，为Parser记录错误
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg) // 记录error
+	d := diagnostic.Diagnostic{
+		Code:     diagnostic.UnexpectedToken,
+		Message:  fmt.Sprintf("expected next token to be %s, got %s instead", tokenName(t), tokenName(p.peekToken.Type)),
+		Pos:      p.peekToken.Pos,
+		End:      p.peekToken.End,
+		Expected: []token.TokenType{t},
+		Actual:   p.peekToken.Type,
+	}
+	switch t {
+	case token.RPAREN, token.RBRACKET, token.RBRACE, token.COLON, token.ASSIGN, token.LPAREN, token.LBRACE:
+		// 缺少分隔符是最常见的情况，可以直接给出修复建议
+		d.Suggestion = fmt.Sprintf("insert %q before %q", t, p.peekToken.Literal)
+	}
+	p.error(d)
 }
 
 // noPrefixParseFnError 为了提供更详细的 解析函数 未匹配信息
 func (p *Parser) noPrefixParseFnError(tokenType token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", tokenType)
-	p.errors = append(p.errors, msg)
+	p.error(diagnostic.Diagnostic{
+		Code:    diagnostic.NoPrefixParseFn,
+		Message: fmt.Sprintf("no prefix parse function for %s found", tokenName(tokenType)),
+		Pos:     p.curToken.Pos,
+		End:     p.curToken.End,
+		Actual:  tokenType,
+	})
+}
+
+// tokenName 返回用于错误信息的token类型名，token.EOF 的值为空字符串，需要特殊处理
+func tokenName(t token.TokenType) string {
+	if t == token.EOF {
+		return "EOF"
+	}
+	return string(t)
 }
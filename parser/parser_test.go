@@ -2,7 +2,9 @@ package parser
 
 import (
 	"Monkey_1/ast"
+	"Monkey_1/diagnostic"
 	"Monkey_1/lexer"
+	"Monkey_1/token"
 	"fmt"
 	"testing"
 )
@@ -180,6 +182,49 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "99999999999999999999999;"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.BigIntegerLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.BigIntegerLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value.String() != "99999999999999999999999" {
+		t.Errorf("literal.Value not %s. got=%s", "99999999999999999999999", literal.Value)
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e3", 1000},
+		{"2.5e-1", 0.25},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
 // TestParsingPrefixExpressions 测试前缀表达式PrefixExpressions
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
@@ -365,6 +410,66 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a*(b[2])), (b[1]), (2*([1,2][1])))",
 		},
+		{
+			"a <= b == c >= d",
+			"((a<=b)==(c>=d))",
+		},
+		{
+			"a + b % c - d",
+			"((a+(b%c))-d)",
+		},
+		{
+			"a | b ^ c & d",
+			"((a|b)^(c&d))",
+		},
+		{
+			"1 << 2 + 3 >> 1",
+			"((1<<2)+(3>>1))",
+		},
+		{
+			"2 ** 3 ** 2",
+			"(2**(3**2))",
+		},
+		{
+			"-2 ** 2",
+			"(-(2**2))",
+		},
+		{
+			"a * b ** c",
+			"(a*(b**c))",
+		},
+		{
+			"a & 1 == 0 && b",
+			"(((a&1)==0)&&b)",
+		},
+		{
+			"a || b && c",
+			"(a||(b&&c))",
+		},
+		{
+			"a && b || c && d",
+			"((a&&b)||(c&&d))",
+		},
+		{
+			"a == 1 && b < 2 || !c",
+			"(((a==1)&&(b<2))||(!c))",
+		},
+		{
+			"x = a || b",
+			"(x=(a||b))",
+		},
+		{
+			"x = y = 1 + 2",
+			"(x=(y=(1+2)))",
+		},
+		{
+			"x += a * b",
+			"(x+=(a*b))",
+		},
+		{
+			"a[i] -= b == c",
+			"((a[i])-=(b==c))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -506,6 +611,309 @@ func TestFunctionParametersParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedParams   []string
+		expectedDefaults []string // 没有默认值的参数为空字符串
+		expectedRest     string
+		expectedString   string
+	}{
+		{"fn(x, y = 10) {}", []string{"x", "y"}, []string{"", "10"}, "",
+			"fn(x, y = 10)"},
+		{"fn(x = 1, y = x * 2) {}", []string{"x", "y"}, []string{"1", "(x*2)"}, "",
+			"fn(x = 1, y = (x*2))"},
+		{"fn(...rest) {}", []string{}, []string{}, "rest",
+			"fn(...rest)"},
+		{"fn(first, second = 2, ...rest) { rest }", []string{"first", "second"}, []string{"", "2"}, "rest",
+			"fn(first, second = 2, ...rest)rest"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("length parameters wrong. want %d, got=%d\n",
+				len(tt.expectedParams), len(function.Parameters))
+		}
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+			defaultValue := function.Defaults[i]
+			if tt.expectedDefaults[i] == "" && defaultValue != nil {
+				t.Errorf("parameter %s should not have default value. got=%s", ident, defaultValue)
+			}
+			if tt.expectedDefaults[i] != "" && (defaultValue == nil || defaultValue.String() != tt.expectedDefaults[i]) {
+				t.Errorf("parameter %s default value wrong. want %s, got=%v", ident, tt.expectedDefaults[i], defaultValue)
+			}
+		}
+		if tt.expectedRest == "" && function.Rest != nil {
+			t.Errorf("function should not have rest parameter. got=%s", function.Rest)
+		}
+		if tt.expectedRest != "" {
+			testIdentifier(t, function.Rest, tt.expectedRest)
+		}
+		if function.String() != tt.expectedString {
+			t.Errorf("function.String() wrong. want %q, got=%q", tt.expectedString, function.String())
+		}
+	}
+}
+
+func TestInvalidFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"fn(x = 1, y) {}", "parameter y without default value follows parameter with default value"},
+		{"fn(...rest, x) {}", "expected next token to be ), got , instead"},
+		{"fn(1) {}", "expected parameter name, got INT instead"},
+		{"fn(...) {}", "expected next token to be IDENT, got ) instead"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%q: expected 1 error. got=%d (%v)", tt.input, len(errors), errors)
+			continue
+		}
+		if errors[0].Message != tt.expectedMessage {
+			t.Errorf("%q: message wrong. expected=%q, got=%q", tt.input, tt.expectedMessage, errors[0].Message)
+		}
+	}
+}
+
+func TestInvalidAssignTarget(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"1 = 2", "cannot assign to 1"},
+		{"f() += 1", "cannot assign to f()"},
+		{"a + b = c", "cannot assign to (a+b)"},
+		{"a[1:2] = c", "cannot assign to (a[1:2])"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%q: expected 1 error. got=%d (%v)", tt.input, len(errors), errors)
+			continue
+		}
+		if errors[0].Code != diagnostic.InvalidAssign {
+			t.Errorf("%q: code wrong. expected=%q, got=%q", tt.input, diagnostic.InvalidAssign, errors[0].Code)
+		}
+		if errors[0].Message != tt.expectedMessage {
+			t.Errorf("%q: message wrong. expected=%q, got=%q", tt.input, tt.expectedMessage, errors[0].Message)
+		}
+	}
+
+	// 左侧本身解析失败时只报告左侧的错误，不能panic
+	for _, input := range []string{"if = 1", "let x = if = 1;", "fn(x = if = 1) {}"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("%q: expected errors", input)
+			continue
+		}
+		for _, err := range errors {
+			if err.Code == diagnostic.InvalidAssign {
+				t.Errorf("%q: unexpected error %v", input, err)
+			}
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 10) { x += 1; if (x == 5) { break; } continue; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.WhileStatement. got=%T", program.Statements[0])
+	}
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+	if len(stmt.Body.Statements) != 3 {
+		t.Fatalf("body does not contain 3 statements. got=%d", len(stmt.Body.Statements))
+	}
+	if _, ok := stmt.Body.Statements[2].(*ast.ContinueStatement); !ok {
+		t.Errorf("body.Statements[2] is not *ast.ContinueStatement. got=%T", stmt.Body.Statements[2])
+	}
+	if stmt.String() != "while(x<10) (x+=1)if(x==5) break;continue;" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+
+	testUnclosedLoopBody(t, "while (x) { 1")
+}
+
+// testUnclosedLoopBody 缺少 } 的循环体应该报告P001并给出建议
+func testUnclosedLoopBody(t *testing.T, input string) {
+	t.Helper()
+	p := New(lexer.New(input))
+	p.ParseProgram()
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("%q: expected 1 error. got=%d (%v)", input, len(errors), errors)
+	}
+	if errors[0].Code != diagnostic.UnexpectedToken {
+		t.Errorf("%q: code wrong. expected=%q, got=%q", input, diagnostic.UnexpectedToken, errors[0].Code)
+	}
+	if errors[0].Suggestion != `insert "}" to close the loop body` {
+		t.Errorf("%q: suggestion wrong. got=%q", input, errors[0].Suggestion)
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (item in [1, 2]) { puts(item); }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ForStatement. got=%T", program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Variable, "item") {
+		return
+	}
+	if stmt.Iterable.String() != "[1,2]" {
+		t.Errorf("stmt.Iterable wrong. got=%q", stmt.Iterable.String())
+	}
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("body does not contain 1 statement. got=%d", len(stmt.Body.Statements))
+	}
+
+	testUnclosedLoopBody(t, "for (a in b) { 1")
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"break;", "break outside loop"},
+		{"if (true) { continue; }", "continue outside loop"},
+		{"while (true) { let f = fn() { break; }; }", "break outside loop"},
+		{"for (x in y) { 1 }; break;", "break outside loop"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%q: expected 1 error. got=%d (%v)", tt.input, len(errors), errors)
+			continue
+		}
+		if errors[0].Code != diagnostic.InvalidBreak || errors[0].Message != tt.expectedMessage {
+			t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, tt.expectedMessage, errors[0])
+		}
+	}
+}
+
+func TestParsingWithComments(t *testing.T) {
+	input := `
+// 计算和
+let add = fn(x, /* 第二个参数 */ y) {
+	x + y // 返回值
+};
+add(1, 2);`
+
+	for _, mode := range []lexer.Mode{0, lexer.ScanComments} {
+		l := lexer.New(input)
+		l.SetMode(mode)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != "let add = fn(x, y)(x+y);add(1, 2)" {
+			t.Errorf("mode %d: program.String() wrong. got=%q", mode, program.String())
+		}
+	}
+}
+
+func TestInterpolatedStringParsing(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedParts int
+		expected      string
+	}{
+		{`"hello ${name}!"`, 3, `"hello ${name}!"`},
+		{`"${a + b}"`, 1, `"${(a+b)}"`},
+		{`"${a}${b}"`, 2, `"${a}${b}"`},
+		{`"x ${"y"} ${len(items)} items"`, 5, `"x ${y} ${len(items)} items"`},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		str, ok := stmt.Expression.(*ast.InterpolatedString)
+		if !ok {
+			t.Fatalf("%s: exp not *ast.InterpolatedString. got=%T", tt.input, stmt.Expression)
+		}
+		if len(str.Parts) != tt.expectedParts {
+			t.Errorf("%s: wrong number of parts. expected=%d, got=%d", tt.input, tt.expectedParts, len(str.Parts))
+		}
+		if str.String() != tt.expected {
+			t.Errorf("%s: String() wrong. expected=%q, got=%q", tt.input, tt.expected, str.String())
+		}
+		if str.End().Offset != len(tt.input) {
+			t.Errorf("%s: End() wrong. got=%s", tt.input, str.End())
+		}
+	}
+}
+
+func TestInvalidInterpolatedString(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`"a ${} b"`, "no prefix parse function for TEMPLATE_TAIL found"},
+		{`"a ${x y} b"`, "expected } to close string interpolation, got IDENT instead"},
+		{`"a ${x`, "expected } to close string interpolation, got EOF instead"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("%s: expected errors", tt.input)
+			continue
+		}
+		if errors[0].Message != tt.expectedMessage {
+			t.Errorf("%s: message wrong. expected=%q, got=%q", tt.input, tt.expectedMessage, errors[0].Message)
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 	l := lexer.New(input)
@@ -610,6 +1018,34 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:3]", "(a[1:3])"},
+		{"a[:3]", "(a[:3])"},
+		{"a[1:]", "(a[1:])"},
+		{"a[:]", "(a[:])"},
+		{"a[::2]", "(a[::2])"},
+		{"a[1:2:3]", "(a[1:2:3])"},
+		{"a[::]", "(a[:])"},
+		{"a[-1:]", "(a[(-1):])"},
+		{"a[i + 1:len(a) - 1:-1]", "(a[(i+1):(len(a)-1):(-1)])"},
+		{"a[1:][0]", "((a[1:])[0])"},
+		{"f(x)[:n * 2]", "(f(x)[:(n*2)])"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	input := `{"one": 1, "two": 2, "three": 3}`
 	l := lexer.New(input)
@@ -634,7 +1070,8 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		"three": 3,
 	}
 
-	for key, value := range exp.Pairs {
+	for _, pair := range exp.Pairs {
+		key, value := pair.Key, pair.Value
 		literal, ok := key.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("key is not ast.StringLiteral. got=%T", key)
@@ -687,7 +1124,8 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 			testInfixExpression(t, e, 15, "/", 5)
 		},
 	}
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		literal, ok := key.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("key is not ast.StringLiteral. got=%T", key)
@@ -702,6 +1140,135 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 	}
 }
 
+func TestHashLiteralSourceOrder(t *testing.T) {
+	input := `{"z": 1, "a": 2, 3: f(), true: "t", "m": [1]}`
+	expected := `{z:1,a:2,3:f(),true:t,m:[1]}`
+
+	// 多次解析，顺序总是与源码相同
+	for i := 0; i < 20; i++ {
+		l := lexer.New(input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != expected {
+			t.Fatalf("hash literal order wrong. expected=%q, got=%q", expected, program.String())
+		}
+	}
+}
+
+func TestLeadingZeroIntegerLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"010", 10},
+		{"0755", 755},
+		{"09", 9},
+		{"0o755", 493},
+		{"0x_ff", 255},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		integ, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("%q: exp not *ast.IntegerLiteral. got=%T", tt.input, stmt.Expression)
+		}
+		if integ.Value != tt.expected {
+			t.Errorf("%q: value wrong. expected=%d, got=%d", tt.input, tt.expected, integ.Value)
+		}
+	}
+}
+
+func TestParserDiagnostics(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedCode     diagnostic.Code
+		expectedPos      string
+		expectedExpected []token.TokenType
+		expectedActual   token.TokenType
+		expectedMessage  string
+	}{
+		{"let x 5;", diagnostic.UnexpectedToken, "1:7",
+			[]token.TokenType{token.ASSIGN}, token.INT,
+			"expected next token to be =, got INT instead"},
+		{"add(1, 2", diagnostic.UnexpectedToken, "1:9",
+			[]token.TokenType{token.RPAREN}, token.EOF,
+			"expected next token to be ), got EOF instead"},
+		{"let x = ;", diagnostic.NoPrefixParseFn, "1:9",
+			nil, token.SEMICOLON,
+			"no prefix parse function for ; found"},
+		{"1e999", diagnostic.InvalidFloat, "1:1",
+			nil, token.FLOAT,
+			`could not parse "1e999" as float`},
+		{"let x = 1 @ 2;", diagnostic.IllegalCharacter, "1:11",
+			nil, token.ILLEGAL,
+			"illegal character '@'"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%q: expected 1 error. got=%d (%v)", tt.input, len(errors), errors)
+			continue
+		}
+		d := errors[0]
+		if d.Severity != diagnostic.Error {
+			t.Errorf("%q: severity wrong. got=%s", tt.input, d.Severity)
+		}
+		if d.Code != tt.expectedCode {
+			t.Errorf("%q: code wrong. expected=%s, got=%s", tt.input, tt.expectedCode, d.Code)
+		}
+		if d.Pos.String() != tt.expectedPos {
+			t.Errorf("%q: pos wrong. expected=%s, got=%s", tt.input, tt.expectedPos, d.Pos)
+		}
+		if fmt.Sprint(d.Expected) != fmt.Sprint(tt.expectedExpected) {
+			t.Errorf("%q: expected tokens wrong. expected=%v, got=%v", tt.input, tt.expectedExpected, d.Expected)
+		}
+		if d.Actual != tt.expectedActual {
+			t.Errorf("%q: actual token wrong. expected=%q, got=%q", tt.input, tt.expectedActual, d.Actual)
+		}
+		if d.Message != tt.expectedMessage {
+			t.Errorf("%q: message wrong. expected=%q, got=%q", tt.input, tt.expectedMessage, d.Message)
+		}
+	}
+}
+
+// TestParserErrorRecovery 一个错误不应引发一连串错误，出错语句之后的语句仍然能正常解析
+func TestParserErrorRecovery(t *testing.T) {
+	input := `
+let x 5 + (3 * ;
+let y = 10;
+let f = fn(a) { let = a; a + 1 };
+f(y);
+`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 2 {
+		t.Fatalf("expected 2 errors. got=%d (%v)", len(errors), errors)
+	}
+	if errors[0].Pos.Line != 2 || errors[1].Pos.Line != 4 {
+		t.Errorf("errors at wrong lines. got=%s, %s", errors[0].Pos, errors[1].Pos)
+	}
+	if errors[1].Suggestion != "" {
+		t.Errorf("unexpected suggestion. got=%q", errors[1].Suggestion)
+	}
+
+	expected := "let y = 10;let f = fn(a)(a+1);f(y)"
+	if program.String() != expected {
+		t.Errorf("program wrong. expected=%q, got=%q", expected, program.String())
+	}
+}
+
 // 辅助测试函数
 // testLetStatement
 func testLetStatement(t *testing.T, s ast.Statement, expectedIdentifier string) bool {
@@ -826,3 +1393,35 @@ func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
 	}
 	return true
 }
+
+func TestNodePositions(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos string
+		expectedEnd string
+	}{
+		{"let x = 1 + 2;", "1:1", "1:14"},
+		{"  a * b", "1:3", "1:8"},
+		{"-a", "1:1", "1:3"},
+		{"add(1,\n 2)", "1:1", "2:4"},
+		{"[1, 2][0]", "1:1", "1:10"},
+		{`{"a": 1}`, "1:1", "1:9"},
+		{"if (x) {\n y\n} else { z }", "1:1", "3:13"},
+		{"fn(x) { x }", "1:1", "1:12"},
+		{"return foo;", "1:1", "1:11"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0]
+		if stmt.Pos().String() != tt.expectedPos {
+			t.Errorf("%q: pos wrong. expected=%s, got=%s", tt.input, tt.expectedPos, stmt.Pos())
+		}
+		if stmt.End().String() != tt.expectedEnd {
+			t.Errorf("%q: end wrong. expected=%s, got=%s", tt.input, tt.expectedEnd, stmt.End())
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package repl
 
 import (
+	"Monkey_1/diagnostic"
 	"Monkey_1/evaluator"
 	"Monkey_1/lexer"
 	"Monkey_1/object"
@@ -22,6 +23,7 @@ const BRAND = ` __  __  ___  _   _ _  _________   __
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
+	interpreter := evaluator.New()
 	for {
 		fmt.Fprintf(out, PROMPT)
 		scanned := scanner.Scan() // 从 in 读入下一行 ，并移除行末的换行符
@@ -51,8 +53,12 @@ func Start(in io.Reader, out io.Writer) {
 			io.WriteString(out, "\n")
 		*/
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
+		evaluated := interpreter.Eval(program, env)
+		if errObj, ok := evaluated.(*object.Error); ok {
+			// 运行时错误打印完整的调用栈
+			io.WriteString(out, errObj.Trace())
+			io.WriteString(out, "\n")
+		} else if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
 		}
@@ -60,10 +66,13 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParseErrors(out io.Writer, errors []string) {
+func printParseErrors(out io.Writer, errors []diagnostic.Diagnostic) {
 	io.WriteString(out, BRAND)
 	io.WriteString(out, "Woops! We ran into some monkey business here!\n parser errors:")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	for _, d := range errors {
+		io.WriteString(out, "\t"+d.String()+"\n")
+		if d.Suggestion != "" {
+			io.WriteString(out, "\t\thelp: "+d.Suggestion+"\n")
+		}
 	}
 }
@@ -1,10 +1,40 @@
 package token
 
+import "fmt"
+
 type TokenType string // 虽然string并不及int或type高效，但可读性强
 
 type Token struct {
 	Type    TokenType // token的类型
 	Literal string    // token的字面值
+	Pos     Position  // token首个字符的位置
+	End     Position  // token最后一个字符之后的位置，即[Pos, End)为token在源码中的范围
+}
+
+// Position 源码中的一个位置
+type Position struct {
+	Filename string // 文件名，可以为空
+	Offset   int    // 字节偏移量，从0开始
+	Line     int    // 行号，从1开始
+	Column   int    // 列号，从1开始
+}
+
+// IsValid 行号从1开始，因此零值Position表示未知位置
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String 返回 file:line:column 形式的位置，没有文件名时返回 line:column
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+	s := fmt.Sprintf("%d:%d", p.Line, p.Column)
+	if p.Filename != "" {
+		s = p.Filename + ":" + s
+	}
+	return s
 }
 
 // TokenType constant
@@ -15,6 +45,14 @@ const (
 	//  Identifiers + literals
 	IDENT = "IDENT" // add, x, y
 	INT   = "INT"   // 1 2 3
+	FLOAT = "FLOAT" // 1.5 2e10 3.0e-2
+
+	COMMENT = "COMMENT" // 只在lexer.ScanComments模式下产生
+
+	// 插值字符串 "a ${x} b ${y} c" 被拆分为 TEMPLATE_HEAD("a ") x TEMPLATE_MIDDLE(" b ") y TEMPLATE_TAIL(" c")
+	TEMPLATE_HEAD   = "TEMPLATE_HEAD"
+	TEMPLATE_MIDDLE = "TEMPLATE_MIDDLE"
+	TEMPLATE_TAIL   = "TEMPLATE_TAIL"
 
 	// 运算符 Operators
 	ASSIGN   = "="
@@ -27,6 +65,25 @@ const (
 	GT       = ">"
 	EQ       = "=="
 	NOT_EQ   = "!="
+	LT_EQ    = "<="
+	GT_EQ    = ">="
+	AND      = "&&"
+	OR       = "||"
+	PERCENT  = "%"
+	POWER    = "**"
+
+	// 位运算符
+	AMPERSAND = "&"
+	PIPE      = "|"
+	CARET     = "^"
+	SHL       = "<<"
+	SHR       = ">>"
+
+	// 复合赋值运算符
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
 
 	// 分隔符 Delimiters
 	COMMA     = ","
@@ -38,6 +95,7 @@ const (
 	RBRACE    = "}"
 	LBRACKET  = "["
 	RBRACKET  = "]"
+	ELLIPSIS  = "..."
 
 	// 关键词 keywords
 	FUNCTION = "FUNCTION"
@@ -48,16 +106,26 @@ const (
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
 	STRING   = "STRING"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
 }
 
 // LookupIdent 查找keywords表格，用于区分用户自定标识符和Monkey关键字，因为他们都是字符串形式的